@@ -0,0 +1,221 @@
+package humane
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// alias returns the alias of a key or the name of a group, or s itself if it
+// has none.
+func (h *handler) alias(s string) string {
+	if a, ok := h.aliases[s]; ok {
+		return a
+	}
+	return s
+}
+
+// aliasFull applies the aliases to each part of a full key such as "req.id".
+// If the handler escapes keys, an escaped separator does not split the key.
+func (h *handler) aliasFull(key string) string {
+	if len(h.aliases) == 0 {
+		return key
+	}
+	parts := splitKey(key, h.groupSep, h.escapeKeys)
+	for i, p := range parts {
+		if a, ok := h.aliases[unescapeKey(p, h.escapeKeys)]; ok {
+			if h.escapeKeys {
+				a = escapeKey(a, h.groupSep)
+			}
+			parts[i] = a
+		}
+	}
+	return strings.Join(parts, h.groupSep)
+}
+
+// checkAliases returns an error if aliases cannot be reversed: if an alias
+// is empty, holds sep, or is itself a key with an alias, or if two keys share
+// an alias. If sep is empty, checkAliases does not look for it, since the
+// separator is not yet known when it parses aliases from text.
+func checkAliases(aliases map[string]string, sep string) error {
+	keys := make([]string, 0, len(aliases))
+	for k := range aliases {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	seen := make(map[string]string, len(aliases))
+	for _, k := range keys {
+		a := aliases[k]
+		if a == "" {
+			return fmt.Errorf("humane: KeyAliases: key %q has an empty alias", k)
+		}
+		if sep != "" && strings.Contains(a, sep) {
+			return fmt.Errorf("humane: KeyAliases: alias %q of key %q holds the group separator %q", a, k, sep)
+		}
+		if _, ok := aliases[a]; ok {
+			return fmt.Errorf("humane: KeyAliases: alias %q of key %q is also a key with an alias", a, k)
+		}
+		if other, ok := seen[a]; ok {
+			return fmt.Errorf("humane: KeyAliases: keys %q and %q share the alias %q", other, k, a)
+		}
+		seen[a] = k
+	}
+	return nil
+}
+
+var errAlias = errors.New("want key=alias pairs separated by commas")
+
+// parseAliases parses aliases written as "key=alias" pairs separated by
+// commas (e.g., "request_id=rid,duration_ms=dur").
+func parseAliases(s string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, item := range splitList(s) {
+		k, a, ok := strings.Cut(item, "=")
+		k, a = strings.TrimSpace(k), strings.TrimSpace(a)
+		if !ok || k == "" || a == "" {
+			return nil, errAlias
+		}
+		aliases[k] = a
+	}
+	if err := checkAliases(aliases, ""); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// KeyExpander returns a function that undoes the KeyAliases of opts in a key
+// as the handler displays it, for tools that parse the handler's output. It
+// expands each group name and key in a dotted key (e.g., "req.rid" becomes
+// "req.request_id"), using the GroupSeparator and EscapeKeys of opts.
+func KeyExpander(opts *Options) func(key string) string {
+	expand := make(map[string]string, len(opts.KeyAliases))
+	for k, a := range opts.KeyAliases {
+		expand[a] = k
+	}
+	sep := opts.GroupSeparator
+	if sep == "" {
+		sep = defaultGroupSeparator
+	}
+	return func(key string) string {
+		if len(expand) == 0 {
+			return key
+		}
+		var b strings.Builder
+		for i, part := range splitKey(key, sep, opts.EscapeKeys) {
+			if i > 0 {
+				b.WriteString(sep)
+			}
+			if k, ok := expand[unescapeKey(part, opts.EscapeKeys)]; ok {
+				if opts.EscapeKeys {
+					k = escapeKey(k, sep)
+				}
+				part = k
+			}
+			b.WriteString(part)
+		}
+		return b.String()
+	}
+}
+
+// splitKey splits a dotted key at each separator that a backslash does not
+// escape.
+func splitKey(key, sep string, escaped bool) []string {
+	if !escaped {
+		return strings.Split(key, sep)
+	}
+	var parts []string
+	start := 0
+	for i := 0; i < len(key); {
+		switch {
+		case key[i] == '\\':
+			i += 2
+		case strings.HasPrefix(key[i:], sep):
+			parts = append(parts, key[start:i])
+			i += len(sep)
+			start = i
+		default:
+			i++
+		}
+	}
+	return append(parts, key[start:])
+}
+
+// unescapeKey removes the backslashes that EscapeKeys adds.
+func unescapeKey(s string, escaped bool) string {
+	if !escaped || !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// escapeKey escapes a part of a key as EscapeKeys does.
+func escapeKey(s, sep string) string {
+	return string(appendEscaped(nil, s, sep))
+}
+
+// abbreviate shortens a value that looks like an identifier, such as a UUID or
+// a long hexadecimal trace ID, to its first AbbreviateIDs characters.
+func (h *handler) abbreviate(s string) string {
+	if h.abbreviateIDs <= 0 || len(s) <= h.abbreviateIDs || !isID(s) {
+		return s
+	}
+	return s[:h.abbreviateIDs] + elided
+}
+
+// minIDDigits is the fewest hexadecimal digits that an identifier has.
+const minIDDigits = 16
+
+// isID reports whether s looks like an identifier: a UUID, or hexadecimal
+// digits, at least minIDDigits of them and at least one of them a letter,
+// optionally separated by hyphens. Without the letter, a long number such as
+// a timestamp or an account number would count as an identifier too.
+func isID(s string) bool {
+	if isUUID(s) {
+		return true
+	}
+	if s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	digits, letters := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+		case c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+			digits++
+			letters++
+		case c != '-':
+			return false
+		}
+	}
+	return digits >= minIDDigits && letters > 0
+}
+
+// isUUID reports whether s has the shape of a UUID: 32 hexadecimal digits in
+// groups of 8, 4, 4, 4, and 12, separated by hyphens.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if c != '-' {
+				return false
+			}
+			continue
+		}
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
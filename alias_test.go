@@ -0,0 +1,160 @@
+package humane_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func TestKeyAliases(t *testing.T) {
+	t.Parallel()
+	aliases := map[string]string{"request_id": "rid", "duration_ms": "dur", "request": "req"}
+	testCases := []struct {
+		name string
+		opts humane.Options
+		want string
+	}{
+		{
+			name: "dotted",
+			want: ` INFO | message | rid=42 req.rid=7 req.dur=3 user=bob` + "\n",
+		},
+		{
+			name: "nested",
+			opts: humane.Options{GroupStyle: humane.GroupNested},
+			want: ` INFO | message | rid=42 req={rid=7 dur=3} user=bob` + "\n",
+		},
+		{
+			name: "priority keys use original names",
+			opts: humane.Options{PriorityKeys: []string{"user", "request.request_id"}},
+			want: ` INFO | message | user=bob req.rid=7 rid=42 req.dur=3` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := tc.opts
+			opts.KeyAliases = aliases
+			opts.ReplaceAttr = removeTime
+			logger := slog.New(humane.NewHandler(&buf, &opts))
+			logger.With("request_id", 42).Info("message",
+				slog.Group("request", "request_id", 7, "duration_ms", 3),
+				"user", "bob",
+			)
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeyAliasesExpanded(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		KeyAliases:  map[string]string{"request_id": "rid", "request": "req"},
+		ExpandLevel: slog.LevelError,
+		ReplaceAttr: removeTime,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.WithGroup("request").Error("failed", "request_id", 7)
+	want := "ERROR | failed |\n    req:\n        rid: 7\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestKeyAliasesInvalid(t *testing.T) {
+	t.Parallel()
+	tests := map[string]map[string]string{
+		"shared":    {"request_id": "id", "user_id": "id"},
+		"empty":     {"request_id": ""},
+		"separator": {"request_id": "req.id"},
+		"chained":   {"request_id": "rid", "rid": "r"},
+	}
+	for name, aliases := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewHandler with aliases %q did not panic", aliases)
+				}
+			}()
+			humane.NewHandler(&bytes.Buffer{}, &humane.Options{KeyAliases: aliases})
+		})
+	}
+}
+
+func TestKeyAliasesPriorityEscaped(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		KeyAliases:   map[string]string{"a.b": "ab"},
+		EscapeKeys:   true,
+		PriorityKeys: []string{`a\.b`},
+		ReplaceAttr:  removeTime,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "user", "bob", "a.b", 7)
+	want := ` INFO | message | ab=7 user=bob` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestKeyExpander(t *testing.T) {
+	t.Parallel()
+	aliases := map[string]string{"request_id": "rid", "request": "req", "a/b": "ab"}
+	testCases := []struct {
+		opts humane.Options
+		key  string
+		want string
+	}{
+		{key: "rid", want: "request_id"},
+		{key: "req.rid", want: "request.request_id"},
+		{key: "req.user", want: "request.user"},
+		{key: "other", want: "other"},
+		{
+			opts: humane.Options{GroupSeparator: "/", EscapeKeys: true},
+			key:  `req/ab`,
+			want: `request/a\/b`,
+		},
+		{
+			opts: humane.Options{GroupSeparator: "/", EscapeKeys: true},
+			key:  `req\/rid`,
+			want: `req\/rid`,
+		},
+	}
+	for _, tc := range testCases {
+		opts := tc.opts
+		opts.KeyAliases = aliases
+		if got := humane.KeyExpander(&opts)(tc.key); got != tc.want {
+			t.Errorf("KeyExpander(%+v)(%q) = %q; want %q", tc.opts, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestAbbreviateIDs(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{AbbreviateIDs: 8, ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message",
+		"uuid", "3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+		"trace", "4BF92F3577B34DA6A3CE929D0E0E4736",
+		"short", "deadbeef",
+		"word", "abcdefghijklmnopqrstuvwxyz",
+		"dashes", "-3f2504e04f8911d39a0c",
+		"ts", "20261018123456789012",
+		"account", "0123456789012345",
+		"digits uuid", "12345678-1234-1234-1234-123456789012",
+	)
+	want := ` INFO | message | uuid=3f2504e0… trace=4BF92F35… short=deadbeef ` +
+		`word=abcdefghijklmnopqrstuvwxyz dashes=-3f2504e04f8911d39a0c ` +
+		`ts=20261018123456789012 account=0123456789012345 "digits uuid"=12345678…` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,369 @@
+package humane
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"unicode/utf8"
+)
+
+// An AnyMode determines how the handler displays maps, slices, structs, and
+// other composite values of [log/slog.KindAny].
+type AnyMode int
+
+const (
+	// AnyDefault displays composite values with [fmt.Sprint] (e.g.,
+	// "map[a:1 b:2]"). This is the default.
+	AnyDefault AnyMode = iota
+
+	// AnyFlatten displays composite values as dotted keys, one for each
+	// field, element, or map entry (e.g., "user.id=7 user.name=bob").
+	// ReplaceAttr sees the composite value first, under its own key, and
+	// then each of the flattened Attrs.
+	AnyFlatten
+
+	// AnyJSON displays composite values as compact JSON (e.g.,
+	// `ids=[7,8]`). Like any other value, the JSON is quoted if it needs
+	// quoting (e.g., `user="{\"id\":7,\"name\":\"bob\"}"`).
+	AnyJSON
+)
+
+var anyModeNames = []string{"default", "flatten", "json"}
+
+// String returns the name of a.
+func (a AnyMode) String() string {
+	return enumString(anyModeNames, "AnyMode", a)
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (a AnyMode) MarshalText() ([]byte, error) {
+	return marshalEnum(anyModeNames, "AnyMode", a)
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (a *AnyMode) UnmarshalText(data []byte) error {
+	return unmarshalEnum(anyModeNames, "AnyMode", data, a)
+}
+
+const (
+	defaultMaxAnyDepth   = 4
+	defaultMaxAnyEntries = 32
+)
+
+// elided stands in for anything that AnyFlatten or AnyJSON leaves out.
+const elided = "…"
+
+// Composite values are decoded from JSON into the following types, which
+// preserve the order of the fields in a struct. Scalars are decoded as string,
+// json.Number, bool, or nil.
+type (
+	jsonMember struct {
+		val any
+		key string
+	}
+	jsonObject struct {
+		members []jsonMember
+		more    int
+	}
+	jsonArray struct {
+		elems []any
+		more  int
+	}
+	// jsonDeep replaces an object or array nested beyond the depth limit.
+	jsonDeep json.Delim
+)
+
+// composite returns a decoded version of v if v is a composite value that
+// AnyFlatten or AnyJSON should display. Errors, Stringers, TextMarshalers, and
+// anything that is not a JSON object or array keep their usual format.
+func (h *handler) composite(v any) (decoded any, ok bool) {
+	switch v.(type) {
+	case nil, error, fmt.Stringer, encoding.TextMarshaler:
+		return nil, false
+	}
+	// A panicking MarshalJSON method means that v keeps its usual format.
+	defer func() {
+		if r := recover(); r != nil {
+			decoded, ok = nil, false
+		}
+	}()
+	data, err := json.Marshal(v)
+	if err != nil || len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return nil, false
+	}
+	d := anyDecoder{
+		dec:        json.NewDecoder(bytes.NewReader(data)),
+		maxDepth:   h.maxAnyDepth,
+		maxEntries: h.maxAnyEntries,
+	}
+	d.dec.UseNumber()
+	decoded, err = d.value(0)
+	if err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
+
+type anyDecoder struct {
+	dec        *json.Decoder
+	maxDepth   int
+	maxEntries int
+}
+
+var errJSONKey = errors.New("humane: JSON object key is not a string")
+
+func (d *anyDecoder) value(depth int) (any, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	if depth >= d.maxDepth {
+		return jsonDeep(delim), d.skipTo(1)
+	}
+	if delim == '{' {
+		return d.object(depth + 1)
+	}
+	return d.array(depth + 1)
+}
+
+func (d *anyDecoder) object(depth int) (jsonObject, error) {
+	var obj jsonObject
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return obj, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return obj, errJSONKey
+		}
+		if len(obj.members) >= d.maxEntries {
+			obj.more++
+			if err := d.skipTo(0); err != nil {
+				return obj, err
+			}
+			continue
+		}
+		val, err := d.value(depth)
+		if err != nil {
+			return obj, err
+		}
+		obj.members = append(obj.members, jsonMember{key: key, val: val})
+	}
+	_, err := d.dec.Token()
+	return obj, err
+}
+
+func (d *anyDecoder) array(depth int) (jsonArray, error) {
+	var arr jsonArray
+	for d.dec.More() {
+		if len(arr.elems) >= d.maxEntries {
+			arr.more++
+			if err := d.skipTo(0); err != nil {
+				return arr, err
+			}
+			continue
+		}
+		val, err := d.value(depth)
+		if err != nil {
+			return arr, err
+		}
+		arr.elems = append(arr.elems, val)
+	}
+	_, err := d.dec.Token()
+	return arr, err
+}
+
+// skipTo consumes tokens until the decoder is no longer nested inside the
+// current value. Pass 0 to skip a whole value or 1 to skip the rest of an
+// object or array whose opening delimiter has already been read.
+func (d *anyDecoder) skipTo(nesting int) error {
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				nesting++
+			} else {
+				nesting--
+			}
+		}
+		if nesting == 0 {
+			return nil
+		}
+	}
+}
+
+// jsonValue converts a decoded composite value into a [log/slog.Value] for
+// AnyFlatten. Objects and arrays become groups, so the handler flattens them
+// exactly as it does any other group. Empty objects and arrays become "{}"
+// and "[]", since an empty group would drop the Attr.
+func jsonValue(v any) slog.Value {
+	switch v := v.(type) {
+	case jsonObject:
+		if len(v.members) == 0 && v.more == 0 {
+			return slog.StringValue("{}")
+		}
+		attrs := make([]slog.Attr, 0, len(v.members)+1)
+		for _, m := range v.members {
+			attrs = append(attrs, slog.Attr{Key: m.key, Value: jsonValue(m.val)})
+		}
+		return slog.GroupValue(appendMore(attrs, v.more)...)
+	case jsonArray:
+		if len(v.elems) == 0 && v.more == 0 {
+			return slog.StringValue("[]")
+		}
+		attrs := make([]slog.Attr, 0, len(v.elems)+1)
+		for i, e := range v.elems {
+			attrs = append(attrs, slog.Attr{Key: strconv.Itoa(i), Value: jsonValue(e)})
+		}
+		return slog.GroupValue(appendMore(attrs, v.more)...)
+	case jsonDeep:
+		return slog.StringValue(deepString(v))
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return slog.Int64Value(n)
+		}
+		if f, err := v.Float64(); err == nil {
+			return slog.Float64Value(f)
+		}
+		return slog.StringValue(v.String())
+	case string:
+		return slog.StringValue(v)
+	case bool:
+		return slog.BoolValue(v)
+	default:
+		return slog.StringValue("null")
+	}
+}
+
+func appendMore(attrs []slog.Attr, more int) []slog.Attr {
+	if more == 0 {
+		return attrs
+	}
+	return append(attrs, slog.String(elided, "+"+strconv.Itoa(more)))
+}
+
+func deepString(d jsonDeep) string {
+	if d == '{' {
+		return "{" + elided + "}"
+	}
+	return "[" + elided + "]"
+}
+
+// appendJSON writes a decoded composite value as compact JSON for AnyJSON.
+// Since w stops copying at its limit, the JSON never grows the buffer by more
+// than that limit.
+func (h *handler) appendJSON(w *limitWriter, v any) {
+	var num [20]byte
+	switch v := v.(type) {
+	case jsonObject:
+		w.WriteByte('{')
+		for i, m := range v.members {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			h.appendJSONString(w, m.key)
+			w.WriteByte(':')
+			h.appendJSON(w, m.val)
+		}
+		if v.more > 0 {
+			if len(v.members) > 0 {
+				w.WriteByte(',')
+			}
+			h.appendJSONString(w, elided)
+			w.WriteByte(':')
+			w.Write(strconv.AppendInt(num[:0], int64(v.more), 10))
+		}
+		w.WriteByte('}')
+	case jsonArray:
+		w.WriteByte('[')
+		for i, e := range v.elems {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			h.appendJSON(w, e)
+		}
+		if v.more > 0 {
+			if len(v.elems) > 0 {
+				w.WriteByte(',')
+			}
+			h.appendJSONString(w, elided+"+"+strconv.Itoa(v.more))
+		}
+		w.WriteByte(']')
+	case jsonDeep:
+		w.WriteString(deepString(v))
+	case json.Number:
+		w.WriteString(v.String())
+	case string:
+		h.appendJSONString(w, v)
+	case bool:
+		w.Write(strconv.AppendBool(num[:0], v))
+	default:
+		w.WriteString("null")
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString writes s as a JSON string. It escapes quotes, backslashes,
+// and C0 control characters, and appendJSONRune handles the rest.
+func (h *handler) appendJSONString(w *limitWriter, s string) {
+	w.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			i += h.appendJSONRune(w, s[i:])
+			continue
+		}
+		switch {
+		case c == '"' || c == '\\':
+			w.WriteByte('\\')
+			w.WriteByte(c)
+		case c == '\n':
+			w.WriteString(`\n`)
+		case c == '\r':
+			w.WriteString(`\r`)
+		case c == '\t':
+			w.WriteString(`\t`)
+		case c < 0x20 || c == 0x7f:
+			w.WriteString(`\u00`)
+			w.WriteByte(hexDigits[c>>4])
+			w.WriteByte(hexDigits[c&0xf])
+		default:
+			w.WriteByte(c)
+		}
+		i++
+	}
+	w.WriteByte('"')
+}
+
+// appendJSONRune writes the first rune of s, which is not ASCII, and returns
+// its length in bytes. If the handler sanitizes its output, it escapes C1
+// control characters and writes invalid UTF-8 as \ufffd.
+func (h *handler) appendJSONRune(w *limitWriter, s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+	switch {
+	case !h.sanitize:
+		w.WriteString(s[:size])
+	case r == utf8.RuneError && size == 1:
+		w.WriteString(`\ufffd`)
+	case r <= 0x9f:
+		w.WriteString(`\u00`)
+		w.WriteByte(hexDigits[r>>4])
+		w.WriteByte(hexDigits[r&0xf])
+	default:
+		w.WriteString(s[:size])
+	}
+	return size
+}
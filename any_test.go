@@ -0,0 +1,169 @@
+package humane_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+type address struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type user struct {
+	Name    string   `json:"name"`
+	Address address  `json:"address"`
+	Tags    []string `json:"tags"`
+	ID      int      `json:"id"`
+}
+
+var bob = user{
+	ID:      7,
+	Name:    "bob",
+	Address: address{City: "New York", Zip: "10001"},
+	Tags:    []string{"a", "b"},
+}
+
+func TestAnyMode(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name string
+		want string
+		opts humane.Options
+		arg  any
+	}{
+		{
+			name: "default",
+			opts: humane.Options{},
+			arg:  map[string]int{"a": 1, "b": 2},
+			want: ` INFO | message | v="map[a:1 b:2]"` + "\n",
+		},
+		{
+			name: "flatten struct",
+			opts: humane.Options{AnyMode: humane.AnyFlatten},
+			arg:  bob,
+			want: ` INFO | message | v.name=bob v.address.city="New York"` +
+				` v.address.zip=10001 v.tags.0=a v.tags.1=b v.id=7` + "\n",
+		},
+		{
+			name: "flatten map",
+			opts: humane.Options{AnyMode: humane.AnyFlatten},
+			arg:  map[string]any{"b": 2.5, "a": nil},
+			want: ` INFO | message | v.a=null v.b=2.5` + "\n",
+		},
+		{
+			name: "flatten depth limit",
+			opts: humane.Options{AnyMode: humane.AnyFlatten, MaxAnyDepth: 1},
+			arg:  bob,
+			want: ` INFO | message | v.name=bob v.address={…} v.tags=[…] v.id=7` + "\n",
+		},
+		{
+			name: "flatten entry limit",
+			opts: humane.Options{AnyMode: humane.AnyFlatten, MaxAnyEntries: 2},
+			arg:  []int{1, 2, 3, 4, 5},
+			want: ` INFO | message | v.0=1 v.1=2 v.…=+3` + "\n",
+		},
+		{
+			name: "json struct",
+			opts: humane.Options{AnyMode: humane.AnyJSON},
+			arg:  bob,
+			want: ` INFO | message | v="{\"name\":\"bob\",\"address\":{\"city\":\"New York\",\"zip\":\"10001\"},\"tags\":[\"a\",\"b\"],\"id\":7}"` + "\n",
+		},
+		{
+			name: "json numbers",
+			opts: humane.Options{AnyMode: humane.AnyJSON},
+			arg:  []int{7, 8},
+			want: ` INFO | message | v=[7,8]` + "\n",
+		},
+		{
+			name: "json limits",
+			opts: humane.Options{AnyMode: humane.AnyJSON, MaxAnyDepth: 1, MaxAnyEntries: 3},
+			arg:  bob,
+			want: ` INFO | message | v="{\"name\":\"bob\",\"address\":{…},\"tags\":[…],\"…\":1}"` + "\n",
+		},
+		{
+			name: "json array entry limit",
+			opts: humane.Options{AnyMode: humane.AnyJSON, MaxAnyEntries: 2},
+			arg:  []string{"x", "y\n", "z"},
+			want: ` INFO | message | v="[\"x\",\"y\\n\",\"…+1\"]"` + "\n",
+		},
+		{
+			name: "errors keep their format",
+			opts: humane.Options{AnyMode: humane.AnyJSON},
+			arg:  errors.New("boom"),
+			want: ` INFO | message | v=boom` + "\n",
+		},
+		{
+			name: "flatten empty struct",
+			opts: humane.Options{AnyMode: humane.AnyFlatten},
+			arg:  struct{}{},
+			want: ` INFO | message | v={}` + "\n",
+		},
+		{
+			name: "flatten empty values",
+			opts: humane.Options{AnyMode: humane.AnyFlatten},
+			arg:  map[string]any{"m": map[string]int{}, "s": []int{}},
+			want: ` INFO | message | v.m={} v.s=[]` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := tc.opts
+			opts.ReplaceAttr = removeTime
+			logger := slog.New(humane.NewHandler(&buf, &opts))
+			logger.Info("message", "v", tc.arg)
+			got := buf.String()
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAnyFlattenReplaceAttr(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		AnyMode: humane.AnyFlatten,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 1 && groups[0] == "user" && a.Key == "zip" {
+				return slog.Attr{}
+			}
+			return removeTime(groups, a)
+		},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "user", address{City: "Paris", Zip: "75001"})
+	got := buf.String()
+	want := " INFO | message | user.city=Paris\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestAnyFlattenReplaceAttrByKey(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		AnyMode: humane.AnyFlatten,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == "user" {
+				return slog.String("user", "REDACTED")
+			}
+			return removeTime(groups, a)
+		},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "user", bob, "addr", address{City: "Paris", Zip: "75001"})
+	got := buf.String()
+	want := " INFO | message | user=REDACTED addr.city=Paris addr.zip=75001\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package humane
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// colorReset ends the SGR escape sequences in which the handler wraps the
+// level, and the values of ColorKeys, in color mode (e.g.,
+// "\x1b[32m INFO\x1b[0m").
+const colorReset = "\x1b[0m"
+
+// levelColor returns the SGR sequence for a level. A level between the
+// standard levels takes the color of the standard level below it.
+func levelColor(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "\x1b[1;31m" // bold red
+	case l >= slog.LevelWarn:
+		return "\x1b[33m" // yellow
+	case l >= slog.LevelInfo:
+		return "\x1b[32m" // green
+	case l >= slog.LevelDebug:
+		return "\x1b[34m" // blue
+	}
+	return "\x1b[2m" // faint
+}
+
+// valueColors is the palette for the values of ColorKeys. It holds teals,
+// purples, and pinks from the 256-color palette, which stand apart from the
+// red, yellow, green, and blue of the levels.
+var valueColors = []string{
+	"\x1b[38;5;37m",
+	"\x1b[38;5;44m",
+	"\x1b[38;5;80m",
+	"\x1b[38;5;152m",
+	"\x1b[38;5;99m",
+	"\x1b[38;5;104m",
+	"\x1b[38;5;135m",
+	"\x1b[38;5;141m",
+	"\x1b[38;5;171m",
+	"\x1b[38;5;177m",
+	"\x1b[38;5;207m",
+	"\x1b[38;5;213m",
+}
+
+// valueColor returns the palette's color for a value. It hashes the value
+// with 32-bit FNV-1a, so that equal values always get the same color.
+func valueColor(val []byte) string {
+	hash := uint32(2166136261)
+	for _, c := range val {
+		hash ^= uint32(c)
+		hash *= 16777619
+	}
+	return valueColors[hash%uint32(len(valueColors))]
+}
+
+// splitColorKeys splits each of ColorKeys into the names of its groups and
+// its key, so that the handler can match them without joining keys.
+func splitColorKeys(keys []string, sep string) [][]string {
+	parts := make([][]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, strings.Split(k, sep))
+	}
+	return parts
+}
+
+// colorsValue reports whether the handler colors the value of the Attr with
+// the given groups and key.
+func (h *handler) colorsValue(groups []string, key string) bool {
+	for _, parts := range h.colorKeys {
+		if matchesKey(parts, groups, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesKey reports whether the parts of a full key name the Attr with the
+// given groups and key.
+func matchesKey(parts, groups []string, key string) bool {
+	n := len(parts) - 1
+	return parts[n] == key && slices.Equal(parts[:n], groups)
+}
+
+// colorFrom colors the value that has been written to buf starting at start.
+func colorFrom(buf *buffer.Buffer, start int) {
+	wrapFrom(buf, start, valueColor((*buf)[start:]))
+}
+
+// wrapFrom puts the SGR sequence code before the text that has been written to
+// buf starting at start, and a reset after it.
+func wrapFrom(buf *buffer.Buffer, start int, code string) {
+	if len(*buf) == start {
+		return
+	}
+	end := len(*buf)
+	buf.WriteString(code)
+	copy((*buf)[start+len(code):], (*buf)[start:end])
+	copy((*buf)[start:], code)
+	buf.WriteString(colorReset)
+}
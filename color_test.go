@@ -0,0 +1,130 @@
+package humane_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func TestColorLevels(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{Color: humane.Always, Level: humane.LevelTrace, ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Log(context.Background(), humane.LevelTrace, "t")
+	logger.Debug("d")
+	logger.Info("i")
+	logger.Warn("w")
+	logger.Error("e")
+	logger.Log(context.Background(), slog.LevelWarn+2, "w2")
+	want := "\x1b[2mTRACE\x1b[0m | t |\n" +
+		"\x1b[34mDEBUG\x1b[0m | d |\n" +
+		"\x1b[32m INFO\x1b[0m | i |\n" +
+		"\x1b[33m WARN\x1b[0m | w |\n" +
+		"\x1b[1;31mERROR\x1b[0m | e |\n" +
+		"\x1b[33m WARN+2\x1b[0m | w2 |\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+// coloredValue matches a value of ColorKeys and captures its color.
+var coloredValue = regexp.MustCompile(`(\x1b\[38;5;\d+m)[^\x1b]*\x1b\[0m`)
+
+func TestColorKeys(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		Color:       humane.Always,
+		ColorKeys:   []string{"request_id", "req.conn"},
+		ReplaceAttr: removeTime,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	ids := []string{"a1", "b2", "c3", "d4", "e5", "f6", "g7", "h8"}
+	for _, id := range ids {
+		logger.Info("first", "request_id", id, "other", id)
+	}
+	for _, id := range ids {
+		logger.Info("second", "request_id", id)
+	}
+	logger.WithGroup("req").Info("grouped", "conn", 7, "request_id", "x")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	colors := map[string]bool{}
+	for i, id := range ids {
+		first := coloredValue.FindStringSubmatch(lines[i])
+		second := coloredValue.FindStringSubmatch(lines[len(ids)+i])
+		if first == nil || second == nil {
+			t.Fatalf("lines %q and %q: want a colored request_id", lines[i], lines[len(ids)+i])
+		}
+		if first[1] != second[1] {
+			t.Errorf("request_id=%s: colors %q and %q; want the same color", id, first[1], second[1])
+		}
+		if !strings.HasSuffix(lines[i], " other="+id) {
+			t.Errorf("got %q; want other=%s without color", lines[i], id)
+		}
+		colors[first[1]] = true
+	}
+	if len(colors) < 2 {
+		t.Errorf("%d ids got %d colors; want more than one", len(ids), len(colors))
+	}
+	grouped := lines[len(lines)-1]
+	if got := coloredValue.FindAllString(grouped, -1); len(got) != 1 || !strings.Contains(got[0], "7") {
+		t.Errorf("got %q; want only req.conn colored", grouped)
+	}
+}
+
+func TestColorNever(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		Terminal:    humane.Always,
+		Color:       humane.Never,
+		ColorKeys:   []string{"request_id"},
+		ReplaceAttr: removeTime,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "request_id", 42)
+	want := " INFO | message | request_id=42\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+// t.Setenv does not allow parallel tests.
+//
+//nolint:paralleltest
+func TestNoColorEnv(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	var buf bytes.Buffer
+	opts := &humane.Options{Terminal: humane.Always, ReplaceAttr: removeTime}
+	slog.New(humane.NewHandler(&buf, opts)).Info("auto")
+	opts.Color = humane.Always
+	slog.New(humane.NewHandler(&buf, opts)).Info("always")
+	want := " INFO | auto |\n\x1b[32m INFO\x1b[0m | always |\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestColorMaxLineLen(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		Color:       humane.Always,
+		ColorKeys:   []string{"id"},
+		MaxLineLen:  27,
+		ReplaceAttr: removeTime,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	// The line cut falls inside the color sequence of the id.
+	logger.Info("m", "id", "abc")
+	want := "\x1b[32m INFO\x1b[0m | m | id=\x1b[0m…(truncated)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
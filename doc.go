@@ -11,27 +11,15 @@
 //
 // 2. Get a slog logger using humane's handler with customized options:
 //
-//	func trimSource(_ []string, a slog.Attr) slog.Attr {
-//		if a.Key == slog.SourceKey {
-//			return slog.String(
-//				slog.SourceKey,
-//				filepath.Base(a.Value.String()),
-//			)
-//		}
-//		return a
-//	}
-//
-//	func main() {
-//		opts := &humane.Options{
-//			Level:       slog.LevelError,
-//			ReplaceAttr: trimSource,
-//			TimeFormat:  time.Kitchen,
-//			AddSource:   true,
-//		}
-//		logger := slog.New(humane.NewHandler(os.Stderr, opts))
-//		// ... later
-//		logger.Error("Message", "error", err, "response", respStatus)
+//	opts := &humane.Options{
+//		Level:      slog.LevelError,
+//		TimeFormat: time.Kitchen,
+//		AddSource:  true,
+//		SourceMode: humane.SourceBase,
 //	}
+//	logger := slog.New(humane.NewHandler(os.Stderr, opts))
+//	// ... later
+//	logger.Error("Message", "error", err, "response", respStatus)
 //
 // [this is the inspiration]: https://brandur.org/logfmt#human
 package humane
@@ -0,0 +1,280 @@
+package humane
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OptionsFromEnv returns Options built from environment variables whose names
+// start with prefix and an underscore. For example, if prefix is "HUMANE",
+// OptionsFromEnv reads the following variables.
+//
+//	HUMANE_LEVEL               trace, debug, info, warn, error, or e.g. info+2
+//	HUMANE_EXPAND_LEVEL        a level, as for HUMANE_LEVEL
+//	HUMANE_TIME_FORMAT         a layout for [time.Time.Format]
+//	HUMANE_TIME_MODE           absolute, since-start, since-last, or clock
+//	HUMANE_TIME_PLACEMENT      trailing or leading
+//	HUMANE_ANY_MODE            default, flatten, or json
+//	HUMANE_MAX_ANY_DEPTH       a number
+//	HUMANE_MAX_ANY_ENTRIES     a number
+//	HUMANE_MAX_VALUE_LEN       a number
+//	HUMANE_MAX_LINE_LEN        a number
+//	HUMANE_MAX_ATTRS           a number
+//	HUMANE_ABBREVIATE_IDS      a number
+//	HUMANE_DUPLICATE_KEYS      keep, last-wins, or first-wins
+//	HUMANE_GROUP_STYLE         dotted or nested
+//	HUMANE_GROUP_SEPARATOR     the text between groups and keys
+//	HUMANE_PRIORITY_KEYS       keys separated by commas (e.g., "request_id,user")
+//	HUMANE_KEY_ALIASES         pairs separated by commas (e.g., "request_id=rid")
+//	HUMANE_ERROR_INTERVAL      a duration for [time.ParseDuration]
+//	HUMANE_WRITE_RETRIES       a number
+//	HUMANE_SOURCE_MODE         full, base, relative, or func
+//	HUMANE_SOURCE_LINK_FORMAT  a URL template
+//	HUMANE_TERMINAL            auto, always, or never
+//	HUMANE_SANITIZE            auto, always, or never
+//	HUMANE_COLOR               auto, always, or never
+//	HUMANE_COLOR_KEYS          keys separated by commas (e.g., "request_id,conn")
+//	HUMANE_HIGHLIGHT           rules separated by ";" (e.g., "status>=500;msg~fail")
+//	HUMANE_LAYOUT              a layout such as "{level} | {msg} | {attrs}"
+//	HUMANE_ADD_SOURCE          a boolean for [strconv.ParseBool]
+//	HUMANE_SOURCE_COLUMN       a boolean
+//	HUMANE_SOURCE_LINKS        a boolean
+//	HUMANE_ESCAPE_KEYS         a boolean
+//	HUMANE_SORT_KEYS           a boolean
+//
+// Each variable sets the option with the same name. The rules of
+// HUMANE_HIGHLIGHT (see [ParseRule]) mark matching lines with "!". An alias in
+// HUMANE_KEY_ALIASES may not hold the group separator, which is "." unless
+// HUMANE_GROUP_SEPARATOR sets another. A variable that is unset or empty
+// leaves its option at the default. If prefix is empty, the names have no
+// prefix or underscore (e.g., "LEVEL").
+//
+// If any variable is invalid, OptionsFromEnv returns an error that names
+// every invalid variable. It also returns the Options built from the valid
+// variables, so that a program can report the error and carry on.
+func OptionsFromEnv(prefix string) (*Options, error) {
+	opts := &Options{}
+	e := envReader{prefix: prefix}
+	e.level("LEVEL", &opts.Level)
+	e.level("EXPAND_LEVEL", &opts.ExpandLevel)
+	e.string("TIME_FORMAT", &opts.TimeFormat)
+	e.text("TIME_MODE", &opts.TimeMode)
+	e.text("TIME_PLACEMENT", &opts.TimePlacement)
+	e.text("ANY_MODE", &opts.AnyMode)
+	e.int("MAX_ANY_DEPTH", &opts.MaxAnyDepth)
+	e.int("MAX_ANY_ENTRIES", &opts.MaxAnyEntries)
+	e.int("MAX_VALUE_LEN", &opts.MaxValueLen)
+	e.int("MAX_LINE_LEN", &opts.MaxLineLen)
+	e.int("MAX_ATTRS", &opts.MaxAttrs)
+	e.int("ABBREVIATE_IDS", &opts.AbbreviateIDs)
+	e.text("DUPLICATE_KEYS", &opts.DuplicateKeys)
+	e.text("GROUP_STYLE", &opts.GroupStyle)
+	e.separator("GROUP_SEPARATOR", &opts.GroupSeparator)
+	e.list("PRIORITY_KEYS", &opts.PriorityKeys)
+	e.aliases("KEY_ALIASES", &opts.KeyAliases)
+	e.duration("ERROR_INTERVAL", &opts.ErrorInterval)
+	e.int("WRITE_RETRIES", &opts.WriteRetries)
+	e.text("SOURCE_MODE", &opts.SourceMode)
+	e.string("SOURCE_LINK_FORMAT", &opts.SourceLinkFormat)
+	e.text("TERMINAL", &opts.Terminal)
+	e.text("SANITIZE", &opts.Sanitize)
+	e.text("COLOR", &opts.Color)
+	e.list("COLOR_KEYS", &opts.ColorKeys)
+	e.rules("HIGHLIGHT", &opts.Highlight)
+	e.text("LAYOUT", &opts.Layout)
+	e.bool("ADD_SOURCE", &opts.AddSource)
+	e.bool("SOURCE_COLUMN", &opts.SourceColumn)
+	e.bool("SOURCE_LINKS", &opts.SourceLinks)
+	e.bool("ESCAPE_KEYS", &opts.EscapeKeys)
+	e.bool("SORT_KEYS", &opts.SortKeys)
+	e.aliasSeparator("KEY_ALIASES", opts)
+	return opts, errors.Join(e.errs...)
+}
+
+// An envReader reads environment variables and collects their errors.
+type envReader struct {
+	prefix string
+	errs   []error
+}
+
+// lookup returns the value of the variable with the given suffix and reports
+// whether it is set and not empty.
+func (e *envReader) lookup(suffix string) (name, value string, ok bool) {
+	name = suffix
+	if e.prefix != "" {
+		name = e.prefix + "_" + suffix
+	}
+	value = os.Getenv(name)
+	return name, value, value != ""
+}
+
+func (e *envReader) fail(name, value string, err error) {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		err = numErr.Err
+	}
+	e.errs = append(e.errs, fmt.Errorf("%s=%q: %w", name, value, err))
+}
+
+func (e *envReader) string(suffix string, p *string) {
+	if _, value, ok := e.lookup(suffix); ok {
+		*p = value
+	}
+}
+
+func (e *envReader) separator(suffix string, p *string) {
+	name, value, ok := e.lookup(suffix)
+	if !ok {
+		return
+	}
+	if err := checkGroupSeparator(value); err != nil {
+		e.fail(name, value, err)
+		return
+	}
+	*p = value
+}
+
+func (e *envReader) list(suffix string, p *[]string) {
+	if _, value, ok := e.lookup(suffix); ok {
+		*p = splitList(value)
+	}
+}
+
+func (e *envReader) aliases(suffix string, p *map[string]string) {
+	name, value, ok := e.lookup(suffix)
+	if !ok {
+		return
+	}
+	aliases, err := parseAliases(value)
+	if err != nil {
+		e.fail(name, value, err)
+		return
+	}
+	*p = aliases
+}
+
+// aliasSeparator checks the aliases that the variable with the given suffix
+// set against the group separator, which is known only once every variable
+// has been read. An alias that holds the separator drops all the aliases.
+func (e *envReader) aliasSeparator(suffix string, opts *Options) {
+	if len(opts.KeyAliases) == 0 {
+		return
+	}
+	sep := opts.GroupSeparator
+	if sep == "" {
+		sep = defaultGroupSeparator
+	}
+	if err := checkAliases(opts.KeyAliases, sep); err != nil {
+		name, value, _ := e.lookup(suffix)
+		e.fail(name, value, err)
+		opts.KeyAliases = nil
+	}
+}
+
+func (e *envReader) rules(suffix string, p *[]Rule) {
+	name, value, ok := e.lookup(suffix)
+	if !ok {
+		return
+	}
+	var rules []Rule
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		r, err := parseFlagRule(item)
+		if err != nil {
+			e.fail(name, value, err)
+			return
+		}
+		rules = append(rules, r)
+	}
+	*p = rules
+}
+
+func (e *envReader) text(suffix string, p encoding.TextUnmarshaler) {
+	name, value, ok := e.lookup(suffix)
+	if !ok {
+		return
+	}
+	if err := p.UnmarshalText([]byte(value)); err != nil {
+		e.fail(name, value, err)
+	}
+}
+
+func (e *envReader) level(suffix string, p *slog.Leveler) {
+	name, value, ok := e.lookup(suffix)
+	if !ok {
+		return
+	}
+	l, err := ParseLevel(value)
+	if err != nil {
+		e.fail(name, value, err)
+		return
+	}
+	*p = l
+}
+
+var errNegative = errors.New("must not be negative")
+
+func (e *envReader) int(suffix string, p *int) {
+	name, value, ok := e.lookup(suffix)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	switch {
+	case err != nil:
+		e.fail(name, value, err)
+	case n < 0:
+		e.fail(name, value, errNegative)
+	default:
+		*p = n
+	}
+}
+
+func (e *envReader) duration(suffix string, p *time.Duration) {
+	name, value, ok := e.lookup(suffix)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	switch {
+	case err != nil:
+		e.fail(name, value, err)
+	case d < 0:
+		e.fail(name, value, errNegative)
+	default:
+		*p = d
+	}
+}
+
+func (e *envReader) bool(suffix string, p *bool) {
+	name, value, ok := e.lookup(suffix)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.fail(name, value, err)
+		return
+	}
+	*p = b
+}
+
+// splitList splits a list of items separated by commas, dropping spaces
+// around each item and any empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
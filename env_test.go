@@ -0,0 +1,169 @@
+package humane_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/telemachus/humane"
+)
+
+// t.Setenv does not allow parallel tests.
+//
+//nolint:paralleltest
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("TEST_LEVEL", "warn")
+	t.Setenv("TEST_TIME_MODE", "Since-Start")
+	t.Setenv("TEST_ANY_MODE", "json")
+	t.Setenv("TEST_MAX_ATTRS", "3")
+	t.Setenv("TEST_ERROR_INTERVAL", "5s")
+	t.Setenv("TEST_SOURCE_MODE", "base")
+	t.Setenv("TEST_TERMINAL", "never")
+	t.Setenv("TEST_ADD_SOURCE", "true")
+	t.Setenv("TEST_SOURCE_COLUMN", "")
+	t.Setenv("TEST_SORT_KEYS", "1")
+	t.Setenv("TEST_COLOR", "always")
+	t.Setenv("TEST_COLOR_KEYS", "request_id")
+	t.Setenv("TEST_HIGHLIGHT", "status>=500; msg~timeout;")
+	t.Setenv("TEST_PRIORITY_KEYS", " request_id, user,")
+	opts, err := humane.OptionsFromEnv("TEST")
+	if err != nil {
+		t.Fatalf("OptionsFromEnv: %v", err)
+	}
+	if opts.Level == nil || opts.Level.Level() != slog.LevelWarn {
+		t.Errorf("Level = %v; want %v", opts.Level, slog.LevelWarn)
+	}
+	if opts.TimeMode != humane.TimeSinceStart {
+		t.Errorf("TimeMode = %v; want %v", opts.TimeMode, humane.TimeSinceStart)
+	}
+	if opts.AnyMode != humane.AnyJSON {
+		t.Errorf("AnyMode = %v; want %v", opts.AnyMode, humane.AnyJSON)
+	}
+	if opts.MaxAttrs != 3 {
+		t.Errorf("MaxAttrs = %d; want 3", opts.MaxAttrs)
+	}
+	if opts.ErrorInterval != 5*time.Second {
+		t.Errorf("ErrorInterval = %v; want 5s", opts.ErrorInterval)
+	}
+	if opts.SourceMode != humane.SourceBase || opts.Terminal != humane.Never {
+		t.Errorf("SourceMode, Terminal = %v, %v; want base, never", opts.SourceMode, opts.Terminal)
+	}
+	if !opts.AddSource || opts.SourceColumn {
+		t.Errorf("AddSource, SourceColumn = %t, %t; want true, false", opts.AddSource, opts.SourceColumn)
+	}
+	if !opts.SortKeys || !slices.Equal(opts.PriorityKeys, []string{"request_id", "user"}) {
+		t.Errorf("SortKeys, PriorityKeys = %t, %q; want true, [request_id user]", opts.SortKeys, opts.PriorityKeys)
+	}
+	if opts.Color != humane.Always || !slices.Equal(opts.ColorKeys, []string{"request_id"}) {
+		t.Errorf("Color, ColorKeys = %v, %q; want always, [request_id]", opts.Color, opts.ColorKeys)
+	}
+	if len(opts.Highlight) != 2 || opts.Highlight[1].String() != "msg~timeout" || opts.Highlight[1].Marker != "!" {
+		t.Errorf("Highlight = %v; want [status>=500 msg~timeout] with marker !", opts.Highlight)
+	}
+	var buf bytes.Buffer
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("got %q; want nothing below the level from the environment", buf.String())
+	}
+}
+
+//nolint:paralleltest
+func TestOptionsFromEnvErrors(t *testing.T) {
+	t.Setenv("TEST_LEVEL", "loud")
+	t.Setenv("TEST_MAX_LINE_LEN", "-1")
+	t.Setenv("TEST_WRITE_RETRIES", "two")
+	t.Setenv("TEST_DUPLICATE_KEYS", "newest")
+	t.Setenv("TEST_SOURCE_LINKS", "yes")
+	t.Setenv("TEST_KEY_ALIASES", "request_id")
+	t.Setenv("TEST_GROUP_SEPARATOR", " | ")
+	t.Setenv("TEST_ANY_MODE", "flatten")
+	opts, err := humane.OptionsFromEnv("TEST")
+	if err == nil {
+		t.Fatal("OptionsFromEnv: got nil error")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`TEST_LEVEL="loud"`,
+		`TEST_MAX_LINE_LEN="-1": must not be negative`,
+		`TEST_WRITE_RETRIES="two": invalid syntax`,
+		`TEST_DUPLICATE_KEYS="newest": humane: unknown DuplicatePolicy "newest" (want keep, last-wins, first-wins)`,
+		`TEST_SOURCE_LINKS="yes": invalid syntax`,
+		`TEST_KEY_ALIASES="request_id": want key=alias pairs separated by commas`,
+		`TEST_GROUP_SEPARATOR=" | ": humane: GroupSeparator " | ": want text without spaces`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not contain %q", msg, want)
+		}
+	}
+	if opts == nil || opts.AnyMode != humane.AnyFlatten {
+		t.Error("OptionsFromEnv did not keep the valid settings")
+	}
+	if opts != nil && opts.GroupSeparator != "" {
+		t.Errorf("GroupSeparator = %q; want none after an invalid separator", opts.GroupSeparator)
+	}
+	if opts != nil && opts.Level != nil {
+		t.Errorf("Level = %v; want nil after an invalid level", opts.Level)
+	}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) || len(joined.Unwrap()) != 7 {
+		t.Errorf("error should join seven errors: %v", err)
+	}
+}
+
+//nolint:paralleltest
+func TestOptionsFromEnvAliasSeparator(t *testing.T) {
+	tests := map[string]struct {
+		aliases string
+		sep     string
+		wantErr bool
+	}{
+		"default separator":    {aliases: "request_id=r.id", wantErr: true},
+		"other separator":      {aliases: "request_id=r.id", sep: "/"},
+		"alias with separator": {aliases: "request_id=r/id", sep: "/", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("TEST_KEY_ALIASES", tc.aliases)
+			t.Setenv("TEST_GROUP_SEPARATOR", tc.sep)
+			opts, err := humane.OptionsFromEnv("TEST")
+			if !tc.wantErr {
+				if err != nil || len(opts.KeyAliases) != 1 {
+					t.Errorf("OptionsFromEnv = %q, %v; want one alias, nil", opts.KeyAliases, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "TEST_KEY_ALIASES=") {
+				t.Errorf("OptionsFromEnv: got error %v; want one for TEST_KEY_ALIASES", err)
+			}
+			if opts.KeyAliases != nil {
+				t.Errorf("KeyAliases = %q; want nil after an invalid alias", opts.KeyAliases)
+			}
+			// The options must not make NewHandler panic.
+			humane.NewHandler(io.Discard, opts)
+		})
+	}
+}
+
+func TestEnumText(t *testing.T) {
+	t.Parallel()
+	text, err := humane.TimeSinceLast.MarshalText()
+	if err != nil || string(text) != "since-last" {
+		t.Errorf("MarshalText() = %q, %v; want %q, nil", text, err, "since-last")
+	}
+	if _, err := humane.SourceMode(9).MarshalText(); err == nil {
+		t.Error("MarshalText of an invalid SourceMode: got nil error")
+	}
+	if got := humane.SourceMode(9).String(); got != "SourceMode(9)" {
+		t.Errorf("String() = %q; want %q", got, "SourceMode(9)")
+	}
+	var w humane.When
+	if err := w.UnmarshalText([]byte("ALWAYS")); err != nil || w != humane.Always {
+		t.Errorf("UnmarshalText(ALWAYS) = %v, %v; want always, nil", w, err)
+	}
+}
@@ -0,0 +1,175 @@
+package humane
+
+import (
+	"bytes"
+	"log/slog"
+	"slices"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// expandIndent is the indentation of each level of an expanded record.
+const expandIndent = "    "
+
+// A groupOrAttrs records a call to WithGroup or WithAttrs. The handler keeps
+// these only if it expands some records, since an expanded record shows the
+// structure that the preformatted Attrs flatten away.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// expands reports whether the handler expands records at the given level.
+func (h *handler) expands(level slog.Level) bool {
+	return h.expandLevel != nil && level >= h.expandLevel.Level()
+}
+
+// A blockField records where the line of one Attr sits in an expanded record.
+// The line occupies buf[start:end], including the newline before it. key is
+// the key as a dotted key would display it, and groups are the groups that
+// hold the Attr.
+type blockField struct {
+	key    []byte
+	groups []string
+	start  int
+	end    int
+}
+
+// appendBlock writes the Attrs of an expanded record, each on its own line
+// below the record's first line. timeAttr is empty if the record has no time.
+func (h *handler) appendBlock(buf *buffer.Buffer, r *slog.Record, timeAttr slog.Attr, hl *highlight) {
+	start := len(*buf)
+	s := &state{buf: buf, hl: *hl, count: true, expand: true, track: h.tracksKeys(), line: start}
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			s.groups = append(s.groups, goa.group)
+			continue
+		}
+		for _, a := range goa.attrs {
+			h.appendAttr(s, a)
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(s, a)
+		return true
+	})
+	h.arrangeBlock(s, start)
+	s.track = false
+	h.appendDropped(s)
+	s.count = false
+	if h.addSource && !h.sourceField && r.PC != 0 {
+		h.appendSource(s, r.PC)
+	}
+	if !timeAttr.Equal(slog.Attr{}) && !h.timeField {
+		h.appendStateKey(s, nil, timeAttr.Key)
+		h.appendTimeVal(buf, timeAttr)
+		h.endBlockLine(s)
+	}
+	*hl = s.hl
+}
+
+// recordBlockField notes the line of an expanded record that the handler just
+// wrote for the Attr with the given key.
+func (h *handler) recordBlockField(s *state, key string) {
+	if !s.track {
+		return
+	}
+	end := len(*s.buf)
+	h.appendKey(s.buf, s.groups, key)
+	k := slices.Clone((*s.buf)[end+1 : len(*s.buf)-1])
+	*s.buf = (*s.buf)[:end]
+	s.block = append(s.block, blockField{k, slices.Clone(s.groups), s.line - 1, end})
+}
+
+// arrangeBlock applies DuplicateKeys, SortKeys, and PriorityKeys to the lines
+// of an expanded record, which start at start in the buffer. It writes the
+// name of a group above each run of the group's lines, so a group that
+// PriorityKeys splits appears more than once.
+func (h *handler) arrangeBlock(s *state, start int) {
+	if len(s.block) < 2 {
+		return
+	}
+	fields := s.block
+	if h.duplicates != DuplicateKeep {
+		kept := fields[:0]
+		for i, f := range fields {
+			others := fields[i+1:]
+			if h.duplicates == DuplicateFirstWins {
+				others = kept
+			}
+			if !hasBlockKey(others, f.key) {
+				kept = append(kept, f)
+			}
+		}
+		fields = kept
+	}
+	if h.ordersKeys() {
+		slices.SortStableFunc(fields, func(a, b blockField) int {
+			return h.compareKeys(a.key, b.key)
+		})
+	}
+	arranged := buffer.New()
+	defer arranged.Free()
+	var groups []string
+	for _, f := range fields {
+		for i := commonPrefix(groups, f.groups); i < len(f.groups); i++ {
+			h.appendBlockGroup(arranged, i, f.groups[i])
+		}
+		arranged.Write((*s.buf)[f.start:f.end])
+		groups = f.groups
+	}
+	*s.buf = append((*s.buf)[:start], *arranged...)
+	s.opened = commonPrefix(groups, s.groups)
+}
+
+func hasBlockKey(fields []blockField, key []byte) bool {
+	for _, f := range fields {
+		if bytes.Equal(key, f.key) {
+			return true
+		}
+	}
+	return false
+}
+
+// commonPrefix returns the number of groups that a and b share at the start.
+func commonPrefix(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// endBlockLine cuts the line of an expanded record that the handler has just
+// finished to MaxLineLen. It does nothing for a record that is not expanded,
+// whose line the handler cuts as a whole.
+func (h *handler) endBlockLine(s *state) {
+	if s.expand {
+		h.truncateLine(s.buf, s.line)
+	}
+}
+
+// appendBlockGroup writes the line of an expanded record that opens a group,
+// cut to MaxLineLen.
+func (h *handler) appendBlockGroup(buf *buffer.Buffer, depth int, name string) {
+	start := len(*buf) + 1
+	h.appendBlockKey(buf, depth, name)
+	buf.WriteByte(':')
+	h.truncateLine(buf, start)
+}
+
+// appendBlockKey starts a line of an expanded record with a key.
+func (h *handler) appendBlockKey(buf *buffer.Buffer, depth int, key string) {
+	h.appendBlockLine(buf, depth)
+	h.appendKeyText(buf, h.alias(key))
+}
+
+// appendBlockLine starts a line of an expanded record at the given depth,
+// after a blank marker column if the rules have markers.
+func (h *handler) appendBlockLine(buf *buffer.Buffer, depth int) {
+	buf.WriteByte('\n')
+	buf.WriteString(h.blankColumn)
+	for i := 0; i <= depth; i++ {
+		buf.WriteString(expandIndent)
+	}
+}
@@ -0,0 +1,163 @@
+package humane_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/telemachus/humane"
+)
+
+func TestExpandLevel(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ExpandLevel: slog.LevelWarn, ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts)).With("app", "api")
+	logger.Info("fine", "a", 1)
+	logger.WithGroup("req").With("id", "x 1").Error("failed",
+		"status", 500,
+		slog.Group("user", "id", 7, "name", "bob"),
+		slog.Group("empty"),
+	)
+	got := buf.String()
+	want := " INFO | fine | app=api a=1\n" +
+		"ERROR | failed |\n" +
+		"    app: api\n" +
+		"    req:\n" +
+		"        id: \"x 1\"\n" +
+		"        status: 500\n" +
+		"        user:\n" +
+		"            id: 7\n" +
+		"            name: bob\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExpandLazyGroups(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ExpandLevel: slog.LevelWarn, ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.WithGroup("req").Warn("no attrs")
+	logger.Warn("top", slog.Group("g", slog.Group("inner", "a", 1)), "b", 2)
+	got := buf.String()
+	want := " WARN | no attrs |\n" +
+		" WARN | top |\n" +
+		"    g:\n" +
+		"        inner:\n" +
+		"            a: 1\n" +
+		"    b: 2\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExpandTimeAndLimits(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ExpandLevel: slog.LevelError,
+		TimeFormat:  time.Kitchen,
+		MaxAttrs:    2,
+	}
+	h := humane.NewHandler(&buf, opts)
+	r := slog.NewRecord(time.Date(2023, 4, 2, 10, 50, 9, 0, time.UTC), slog.LevelError, "boom", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Int("b", 2), slog.Int("c", 3))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	got := buf.String()
+	want := "ERROR | boom |\n" +
+		"    a: 1\n" +
+		"    b: 2\n" +
+		"    …(+1 attrs)\n" +
+		"    time: 10:50AM\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExpandMaxLineLen(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ExpandLevel: slog.LevelWarn, ReplaceAttr: removeTime, MaxLineLen: 24}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Warn("boom",
+		"body", strings.Repeat("x", 40),
+		slog.Group("a_very_long_group_name", "k", 1),
+		"short", 1,
+	)
+	got := buf.String()
+	want := " WARN | boom |\n" +
+		"    body: \"xxxxxxxxxxxxx…(truncated)\n" +
+		"    a_very_long_group_na…(truncated)\n" +
+		"        k: 1\n" +
+		"    short: 1\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExpandKeys(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ExpandLevel:   slog.LevelWarn,
+		ReplaceAttr:   removeTime,
+		DuplicateKeys: humane.DuplicateLastWins,
+		SortKeys:      true,
+		PriorityKeys:  []string{"status"},
+		GroupStyle:    humane.GroupNested,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts)).With("b", 1, "status", 200)
+	logger.WithGroup("req").With("path", "/x").Error("failed",
+		"id", 7,
+		slog.Group("user", "name", "bob"),
+		"path", "/y",
+	)
+	logger.Error("split", slog.Group("g", "z", 1), "a", 2, slog.Group("g", "y", 2), "b", 3)
+	got := buf.String()
+	want := "ERROR | failed |\n" +
+		"    status: 200\n" +
+		"    b: 1\n" +
+		"    req:\n" +
+		"        id: 7\n" +
+		"        path: /y\n" +
+		"        user:\n" +
+		"            name: bob\n" +
+		"ERROR | split |\n" +
+		"    status: 200\n" +
+		"    a: 2\n" +
+		"    b: 3\n" +
+		"    g:\n" +
+		"        y: 2\n" +
+		"        z: 1\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExpandMaxAttrsDuplicates(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ExpandLevel:   slog.LevelWarn,
+		ReplaceAttr:   removeTime,
+		DuplicateKeys: humane.DuplicateFirstWins,
+		MaxAttrs:      2,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Warn("boom", "a", 1, "a", 2, "b", 3, "c", 4)
+	got := buf.String()
+	want := " WARN | boom |\n" +
+		"    a: 1\n" +
+		"    b: 3\n" +
+		"    …(+1 attrs)\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
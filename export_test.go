@@ -0,0 +1,15 @@
+package humane
+
+import (
+	"log/slog"
+	"time"
+)
+
+// SetWriteClock replaces the clock that a handler uses to space out its
+// reports of write errors, so that tests need not sleep.
+func SetWriteClock(h slog.Handler, now func() time.Time) {
+	hh := h.(*handler)
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	hh.out.now = now
+}
@@ -0,0 +1,189 @@
+package humane
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// A DuplicatePolicy determines what the handler does when more than one Attr
+// in a record has the same key.
+type DuplicatePolicy int
+
+const (
+	// DuplicateKeep displays every Attr, even if the same key appears more
+	// than once. This is the default.
+	DuplicateKeep DuplicatePolicy = iota
+
+	// DuplicateLastWins displays only the last Attr with a given key.
+	DuplicateLastWins
+
+	// DuplicateFirstWins displays only the first Attr with a given key.
+	DuplicateFirstWins
+)
+
+var duplicatePolicyNames = []string{"keep", "last-wins", "first-wins"}
+
+// String returns the name of d.
+func (d DuplicatePolicy) String() string {
+	return enumString(duplicatePolicyNames, "DuplicatePolicy", d)
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (d DuplicatePolicy) MarshalText() ([]byte, error) {
+	return marshalEnum(duplicatePolicyNames, "DuplicatePolicy", d)
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (d *DuplicatePolicy) UnmarshalText(data []byte) error {
+	return unmarshalEnum(duplicatePolicyNames, "DuplicatePolicy", data, d)
+}
+
+// A field records where one key=value pair sits in a buffer. The pair
+// occupies buf[start:end], including its leading space, and its key occupies
+// buf[start+1:sep].
+type field struct {
+	start int
+	sep   int
+	end   int
+}
+
+func (f field) key(buf []byte) []byte {
+	return buf[f.start+1 : f.sep]
+}
+
+// trackFields reports whether the handler needs to know where each key=value
+// pair sits in a line.
+func (h *handler) trackFields() bool {
+	return h.tracksKeys() && h.groupStyle == GroupDotted
+}
+
+// tracksKeys reports whether the handler applies DuplicateKeys, SortKeys, or
+// PriorityKeys. An expanded record applies them whatever the GroupStyle.
+func (h *handler) tracksKeys() bool {
+	return h.duplicates != DuplicateKeep || h.ordersKeys()
+}
+
+// ordersKeys reports whether the handler reorders the Attrs of a record.
+func (h *handler) ordersKeys() bool {
+	return h.sortKeys || len(h.priorityKeys) > 0
+}
+
+// startFields begins tracking fields for a record or a call to WithAttrs. The
+// handler's own preformatted Attrs begin at offset base in the buffer. Only
+// handlers that track fields pay for the slice that holds them.
+func (h *handler) startFields(s *state, base int) {
+	s.track = true
+	s.fields = make([]field, 0, len(h.fields)+16)
+	for _, f := range h.fields {
+		s.fields = append(s.fields, field{f.start + base, f.sep + base, f.end + base})
+	}
+}
+
+// dedupe applies the handler's DuplicatePolicy to the fields of a record.
+// The fields must be contiguous and must end the buffer.
+func (h *handler) dedupe(s *state) {
+	if h.duplicates == DuplicateKeep || len(s.fields) < 2 {
+		return
+	}
+	buf := *s.buf
+	// Kept fields move toward the front of the buffer, but a field never
+	// moves past its own start. So a field's bytes are intact until the
+	// loop reaches it, and so are the bytes of every later field.
+	kept := s.fields[:0]
+	w := s.fields[0].start
+	for i, f := range s.fields {
+		others := s.fields[i+1:]
+		if h.duplicates == DuplicateFirstWins {
+			others = kept
+		}
+		if hasKey(buf, others, f.key(buf)) {
+			continue
+		}
+		n := copy(buf[w:], buf[f.start:f.end])
+		kept = append(kept, field{w, w + f.sep - f.start, w + n})
+		w += n
+	}
+	*s.buf = buf[:w]
+	s.fields = kept
+}
+
+// orderFields applies SortKeys and PriorityKeys to the fields of a record. The
+// fields must be contiguous and must end the buffer.
+func (h *handler) orderFields(s *state) {
+	if !h.ordersKeys() || len(s.fields) < 2 {
+		return
+	}
+	buf := *s.buf
+	base := s.fields[0].start
+	slices.SortStableFunc(s.fields, func(a, b field) int {
+		return h.compareKeys(a.key(buf), b.key(buf))
+	})
+	sorted := buffer.New()
+	defer sorted.Free()
+	for i, f := range s.fields {
+		w := base + len(*sorted)
+		sorted.Write(buf[f.start:f.end])
+		s.fields[i] = field{w, w + f.sep - f.start, w + f.end - f.start}
+	}
+	copy(buf[base:], *sorted)
+}
+
+// compareKeys orders two keys according to PriorityKeys and SortKeys.
+func (h *handler) compareKeys(a, b []byte) int {
+	if c := cmp.Compare(h.priority(a), h.priority(b)); c != 0 || !h.sortKeys {
+		return c
+	}
+	return bytes.Compare(a, b)
+}
+
+// priority returns the position of key in PriorityKeys, or the number of
+// PriorityKeys if key is not one of them.
+func (h *handler) priority(key []byte) int {
+	for i, k := range h.priorityKeys {
+		if string(key) == k {
+			return i
+		}
+	}
+	return len(h.priorityKeys)
+}
+
+func hasKey(buf []byte, fields []field, key []byte) bool {
+	for _, f := range fields {
+		if bytes.Equal(key, f.key(buf)) {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatsKey reports whether an Attr with the given key repeats the key of a
+// field that s has already written. Such an Attr does not count toward
+// MaxAttrs, since the DuplicatePolicy leaves only one of the two.
+func (h *handler) repeatsKey(s *state, key string) bool {
+	if h.maxAttrs <= 0 || !s.track || h.duplicates == DuplicateKeep {
+		return false
+	}
+	start := len(*s.buf)
+	h.appendKey(s.buf, s.groups, key)
+	k := (*s.buf)[start+1 : len(*s.buf)-1]
+	repeats := hasKey(*s.buf, s.fields, k) || hasBlockKey(s.block, k)
+	*s.buf = (*s.buf)[:start]
+	return repeats
+}
+
+// recordField notes the position of a key=value pair that the handler just
+// wrote. In an expanded record, it first cuts the Attr's line to MaxLineLen,
+// and it notes the line instead.
+func (h *handler) recordField(s *state, start, sep int, key string) {
+	if s.expand {
+		h.endBlockLine(s)
+		h.recordBlockField(s, key)
+		return
+	}
+	if s.track {
+		s.fields = append(s.fields, field{start, sep, len(*s.buf)})
+	}
+}
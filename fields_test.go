@@ -0,0 +1,169 @@
+package humane_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name   string
+		want   string
+		policy humane.DuplicatePolicy
+	}{
+		{
+			name:   "keep",
+			policy: humane.DuplicateKeep,
+			want:   ` INFO | message | a=1 g.b=2 a=3 c=4 g.b=5 a=6` + "\n",
+		},
+		{
+			name:   "last wins",
+			policy: humane.DuplicateLastWins,
+			want:   ` INFO | message | c=4 g.b=5 a=6` + "\n",
+		},
+		{
+			name:   "first wins",
+			policy: humane.DuplicateFirstWins,
+			want:   ` INFO | message | a=1 g.b=2 c=4` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{ReplaceAttr: removeTime, DuplicateKeys: tc.policy}
+			logger := slog.New(humane.NewHandler(&buf, opts))
+			logger = logger.With("a", 1, slog.Group("g", "b", 2)).With("a", 3)
+			logger.Info("message", "c", 4, slog.Group("g", "b", 5), "a", 6)
+			got := buf.String()
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDuplicateKeysWithGroup(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime, DuplicateKeys: humane.DuplicateLastWins}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger = logger.With("a", 1).WithGroup("g").With("a", 2)
+	logger.Info("message", "a", 3)
+	logger.Info("message")
+	got := buf.String()
+	want := ` INFO | message | a=1 g.a=3` + "\n" +
+		` INFO | message | a=1 g.a=2` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestDuplicateKeysMaxAttrs(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name   string
+		want   string
+		policy humane.DuplicatePolicy
+	}{
+		{
+			name:   "last wins",
+			policy: humane.DuplicateLastWins,
+			want:   ` INFO | message | a=3 b=5 …(+1 attrs)` + "\n",
+		},
+		{
+			name:   "first wins",
+			policy: humane.DuplicateFirstWins,
+			want:   ` INFO | message | a=1 b=4 …(+1 attrs)` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{ReplaceAttr: removeTime, DuplicateKeys: tc.policy, MaxAttrs: 2}
+			logger := slog.New(humane.NewHandler(&buf, opts))
+			logger = logger.With("a", 1).With("a", 2)
+			logger.Info("message", "a", 3, "b", 4, "c", 5, "b", 5)
+			got := buf.String()
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		want     string
+		priority []string
+		sort     bool
+	}{
+		{
+			name: "original",
+			want: ` INFO | message | user=bob z=1 req.id=7 b=2 request_id=42 a=3`,
+		},
+		{
+			name: "sorted",
+			sort: true,
+			want: ` INFO | message | a=3 b=2 req.id=7 request_id=42 user=bob z=1`,
+		},
+		{
+			name:     "priority",
+			priority: []string{"request_id", "req.id", "missing"},
+			want:     ` INFO | message | request_id=42 req.id=7 user=bob z=1 b=2 a=3`,
+		},
+		{
+			name:     "priority and sorted",
+			priority: []string{"user"},
+			sort:     true,
+			want:     ` INFO | message | user=bob a=3 b=2 req.id=7 request_id=42 z=1`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{
+				AddSource:    true,
+				SourceMode:   humane.SourceFunc,
+				SortKeys:     tc.sort,
+				PriorityKeys: tc.priority,
+			}
+			logger := slog.New(humane.NewHandler(&buf, opts))
+			logger = logger.With("user", "bob", "z", 1)
+			logger.Info("message", slog.Group("req", "id", 7), "b", 2, "request_id", 42, "a", 3)
+			got := buf.String()
+			// The source and time stay last.
+			want := tc.want + " source=humane_test.TestKeyOrder.func1 time="
+			if !strings.HasPrefix(got, want) {
+				t.Errorf("got %q; want prefix %q", got, want)
+			}
+		})
+	}
+}
+
+func TestKeyOrderWithDuplicates(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr:   removeTime,
+		DuplicateKeys: humane.DuplicateLastWins,
+		SortKeys:      true,
+		PriorityKeys:  []string{"c"},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.With("b", 1, "c", 2).Info("message", "a", 3, "b", 4)
+	got := buf.String()
+	want := ` INFO | message | c=2 a=3 b=4` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,173 @@
+package humane
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// RegisterFlags defines flags on fs for the most common options, and it
+// returns Options that hold the flags' values once fs parses the command line.
+// Pass the Options to [NewHandler] after parsing.
+//
+//	opts := humane.RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//	logger := slog.New(humane.NewHandler(os.Stderr, opts))
+//
+// The flags are -log-level, -log-expand-level, -log-time-format,
+// -log-time-mode, -log-time-placement, -log-any-mode, -log-max-value-len,
+// -log-max-line-len, -log-max-attrs, -log-abbreviate-ids, -log-duplicate-keys,
+// -log-group-style, -log-group-separator, -log-escape-keys, -log-sort-keys,
+// -log-priority-keys, -log-key-aliases, -log-source, -log-source-mode,
+// -log-source-column, -log-source-links, -log-terminal, -log-sanitize,
+// -log-color, -log-color-keys, -log-highlight, and -log-layout. The flags -v
+// and -q lower and raise the level by one step (e.g., from info to debug or
+// from info to warn) each time they appear, whatever the order of the flags.
+// Each -log-highlight flag adds a rule (see [ParseRule]) that marks matching
+// lines with "!". The levels accept the names that [ParseLevel] accepts. An
+// alias in -log-key-aliases may not hold the group separator, so an alias such
+// as "r.id" needs a -log-group-separator flag before -log-key-aliases.
+func RegisterFlags(fs *flag.FlagSet) *Options {
+	opts := &Options{TimeFormat: defaultTimeFormat, GroupSeparator: defaultGroupSeparator}
+	level := &levelFlag{base: defaultLevel}
+	opts.Level = level
+	// The flag package checks each flag as it comes, so an alias is checked
+	// against the group separator set by an earlier flag or the default.
+	sepSet := false
+	fs.Var(level, "log-level", "minimum `level` to log: trace, debug, info, warn, or error")
+	fs.Var(verbosityFlag{level: level, step: -1}, "v", "log more; repeat to log even more")
+	fs.Var(verbosityFlag{level: level, step: 1}, "q", "log less; repeat to log even less")
+	fs.Func("log-expand-level", "display records at or above `level` with one attribute per line", func(s string) error {
+		l, err := ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		opts.ExpandLevel = l
+		return nil
+	})
+	fs.StringVar(&opts.TimeFormat, "log-time-format", opts.TimeFormat, "`layout` for absolute times")
+	fs.TextVar(&opts.TimeMode, "log-time-mode", opts.TimeMode, "how to display the time: absolute, since-start, since-last, or clock")
+	fs.TextVar(&opts.TimePlacement, "log-time-placement", opts.TimePlacement, "where to display the time: trailing or leading")
+	fs.TextVar(&opts.AnyMode, "log-any-mode", opts.AnyMode, "how to display composite values: default, flatten, or json")
+	fs.IntVar(&opts.MaxValueLen, "log-max-value-len", 0, "maximum length of a value in bytes (0 for no limit)")
+	fs.IntVar(&opts.MaxLineLen, "log-max-line-len", 0, "maximum length of a line in bytes (0 for no limit)")
+	fs.IntVar(&opts.MaxAttrs, "log-max-attrs", 0, "maximum number of attributes per record (0 for no limit)")
+	fs.IntVar(&opts.AbbreviateIDs, "log-abbreviate-ids", 0, "shorten identifiers such as UUIDs to `n` characters (0 to display them in full)")
+	fs.TextVar(&opts.DuplicateKeys, "log-duplicate-keys", opts.DuplicateKeys, "what to do with duplicate keys: keep, last-wins, or first-wins")
+	fs.TextVar(&opts.GroupStyle, "log-group-style", opts.GroupStyle, "how to display groups: dotted or nested")
+	fs.Func("log-group-separator", "`text` between the names of groups and keys (default \".\")", func(s string) error {
+		if err := checkGroupSeparator(s); err != nil {
+			return err
+		}
+		if err := checkAliases(opts.KeyAliases, s); err != nil {
+			return err
+		}
+		opts.GroupSeparator = s
+		sepSet = true
+		return nil
+	})
+	fs.BoolVar(&opts.EscapeKeys, "log-escape-keys", false, "escape group separators and backslashes in keys")
+	fs.BoolVar(&opts.SortKeys, "log-sort-keys", false, "display attributes sorted by key")
+	fs.Func("log-priority-keys", "comma-separated `keys` to display before other attributes", func(s string) error {
+		opts.PriorityKeys = splitList(s)
+		return nil
+	})
+	fs.Func("log-key-aliases", "short `aliases` for keys (e.g., \"request_id=rid,duration_ms=dur\")", func(s string) error {
+		aliases, err := parseAliases(s)
+		if err != nil {
+			return err
+		}
+		if err := checkAliases(aliases, opts.GroupSeparator); err != nil {
+			if !sepSet {
+				return fmt.Errorf("%w (put -log-group-separator first to change it)", err)
+			}
+			return err
+		}
+		opts.KeyAliases = aliases
+		return nil
+	})
+	fs.BoolVar(&opts.AddSource, "log-source", false, "display the source of each record")
+	fs.TextVar(&opts.SourceMode, "log-source-mode", opts.SourceMode, "how to display the source: full, base, relative, or func")
+	fs.BoolVar(&opts.SourceColumn, "log-source-column", false, "display the source as its own column")
+	fs.BoolVar(&opts.SourceLinks, "log-source-links", false, "display the source as a terminal hyperlink")
+	fs.TextVar(&opts.Terminal, "log-terminal", opts.Terminal, "whether to treat the output as a terminal: auto, always, or never")
+	fs.TextVar(&opts.Sanitize, "log-sanitize", opts.Sanitize, "whether to escape control characters: auto, always, or never")
+	fs.TextVar(&opts.Color, "log-color", opts.Color, "whether to color the output: auto, always, or never")
+	fs.Func("log-color-keys", "comma-separated `keys` whose values get a color of their own", func(s string) error {
+		opts.ColorKeys = splitList(s)
+		return nil
+	})
+	fs.Func("log-highlight", "mark lines that match a `rule` such as \"status>=500\"; repeat for more rules", func(s string) error {
+		r, err := parseFlagRule(s)
+		if err != nil {
+			return err
+		}
+		opts.Highlight = append(opts.Highlight, r)
+		return nil
+	})
+	fs.TextVar(&opts.Layout, "log-layout", opts.Layout, "`template` for each line (e.g., \"{level} | {msg} | {attrs}\")")
+	return opts
+}
+
+// A levelFlag is a [log/slog.Leveler] that flags set. The level is the base
+// level moved by steps of four, the distance between slog's levels.
+type levelFlag struct {
+	base  slog.Level
+	steps int
+}
+
+func (l *levelFlag) Level() slog.Level {
+	return l.base + slog.Level(4*l.steps)
+}
+
+func (l *levelFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return levelName(l.base)
+}
+
+func (l *levelFlag) Set(s string) error {
+	base, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	l.base = base
+	return nil
+}
+
+// A verbosityFlag moves a levelFlag by step each time it appears. It also
+// accepts a count (e.g., -v=2).
+type verbosityFlag struct {
+	level *levelFlag
+	step  int
+}
+
+func (v verbosityFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (v verbosityFlag) String() string {
+	return ""
+}
+
+func (v verbosityFlag) Set(s string) error {
+	n := 1
+	switch s {
+	case "true":
+	case "false":
+		n = 0
+	default:
+		var err error
+		n, err = strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return errVerbosity
+		}
+	}
+	v.level.steps += n * v.step
+	return nil
+}
+
+var errVerbosity = errors.New("want a count such as 2")
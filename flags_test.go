@@ -0,0 +1,179 @@
+package humane_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func parseFlags(t *testing.T, args ...string) *humane.Options {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	opts := humane.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Parse(%q): %v", args, err)
+	}
+	return opts
+}
+
+func TestRegisterFlags(t *testing.T) {
+	t.Parallel()
+	opts := parseFlags(t,
+		"-log-level=warn",
+		"-log-time-mode=clock",
+		"-log-source",
+		"-log-source-mode=relative",
+		"-log-max-attrs=5",
+		"-log-sort-keys",
+		"-log-priority-keys=request_id,user",
+		"-log-key-aliases=request_id=rid, duration_ms=dur",
+		"-log-abbreviate-ids=8",
+		"-log-color=never",
+		"-log-color-keys=request_id,conn",
+		"-log-highlight=status>=500",
+		"-log-highlight=msg~timeout",
+	)
+	if got := opts.Level.Level(); got != slog.LevelWarn {
+		t.Errorf("Level = %v; want %v", got, slog.LevelWarn)
+	}
+	if opts.TimeMode != humane.TimeClock {
+		t.Errorf("TimeMode = %v; want %v", opts.TimeMode, humane.TimeClock)
+	}
+	if !opts.AddSource || opts.SourceMode != humane.SourceRelative {
+		t.Errorf("AddSource, SourceMode = %t, %v; want true, relative", opts.AddSource, opts.SourceMode)
+	}
+	if opts.MaxAttrs != 5 {
+		t.Errorf("MaxAttrs = %d; want 5", opts.MaxAttrs)
+	}
+	if !opts.SortKeys || !slices.Equal(opts.PriorityKeys, []string{"request_id", "user"}) {
+		t.Errorf("SortKeys, PriorityKeys = %t, %q; want true, [request_id user]", opts.SortKeys, opts.PriorityKeys)
+	}
+	if len(opts.KeyAliases) != 2 || opts.KeyAliases["duration_ms"] != "dur" || opts.AbbreviateIDs != 8 {
+		t.Errorf("KeyAliases, AbbreviateIDs = %q, %d; want two aliases, 8", opts.KeyAliases, opts.AbbreviateIDs)
+	}
+	if opts.Color != humane.Never || !slices.Equal(opts.ColorKeys, []string{"request_id", "conn"}) {
+		t.Errorf("Color, ColorKeys = %v, %q; want never, [request_id conn]", opts.Color, opts.ColorKeys)
+	}
+	if len(opts.Highlight) != 2 || opts.Highlight[0].String() != "status>=500" {
+		t.Errorf("Highlight = %v; want [status>=500 msg~timeout]", opts.Highlight)
+	}
+}
+
+func TestRegisterFlagsDefaults(t *testing.T) {
+	t.Parallel()
+	opts := parseFlags(t)
+	var buf bytes.Buffer
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Debug("hidden")
+	logger.Info("shown")
+	if !bytes.HasPrefix(buf.Bytes(), []byte(" INFO | shown | time=")) {
+		t.Errorf("got %q; want only the info record", buf.String())
+	}
+}
+
+func TestVerbosityFlags(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		args []string
+		want slog.Level
+	}{
+		"v":          {args: []string{"-v"}, want: slog.LevelDebug},
+		"v v":        {args: []string{"-v", "-v"}, want: humane.LevelTrace},
+		"v=2":        {args: []string{"-v=2"}, want: humane.LevelTrace},
+		"q":          {args: []string{"-q"}, want: slog.LevelWarn},
+		"v then set": {args: []string{"-v", "-log-level=error"}, want: slog.LevelWarn},
+		"v and q":    {args: []string{"-q", "-v", "-q"}, want: slog.LevelWarn},
+		"trace":      {args: []string{"-log-level=TRACE"}, want: humane.LevelTrace},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			opts := parseFlags(t, tc.args...)
+			if got := opts.Level.Level(); got != tc.want {
+				t.Errorf("Level = %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLevelFlagErrors(t *testing.T) {
+	t.Parallel()
+	for _, args := range [][]string{
+		{"-log-level=loud"},
+		{"-v=many"},
+		{"-log-any-mode=xml"},
+		{"-log-group-separator=a b"},
+		{"-log-key-aliases=request_id=r.id"},
+		{"-log-key-aliases=request_id=r/id", "-log-group-separator=/"},
+		{"-log-key-aliases=request_id=r.id", "-log-group-separator=/"},
+		{"-log-group-separator=/", "-log-key-aliases=request_id=r/id"},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		humane.RegisterFlags(fs)
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("Parse(%q): got nil error", args)
+		}
+	}
+}
+
+func TestKeyAliasesFlagSeparator(t *testing.T) {
+	t.Parallel()
+	opts := parseFlags(t, "-log-group-separator=/", "-log-key-aliases=request_id=r.id")
+	if opts.KeyAliases["request_id"] != "r.id" || opts.GroupSeparator != "/" {
+		t.Errorf("KeyAliases, GroupSeparator = %q, %q; want r.id, /", opts.KeyAliases, opts.GroupSeparator)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+	tests := map[string]slog.Level{
+		"trace":   humane.LevelTrace,
+		"Trace+2": humane.LevelTrace + 2,
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"warn-1":  slog.LevelWarn - 1,
+		"error":   slog.LevelError,
+	}
+	for s, want := range tests {
+		got, err := humane.ParseLevel(s)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v, nil", s, got, err, want)
+		}
+	}
+	if _, err := humane.ParseLevel("tracer"); err == nil {
+		t.Error(`ParseLevel("tracer"): got nil error`)
+	}
+}
+
+func TestTraceLevel(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{Level: humane.LevelTrace, ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Log(nil, humane.LevelTrace, "foo") //nolint:staticcheck // slog allows a nil context.
+	logger.Log(context.Background(), humane.LevelTrace+1, "bar")
+	got := buf.String()
+	want := "TRACE | foo |\n TRACE+1 | bar |\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestExpandLevelFlag(t *testing.T) {
+	t.Parallel()
+	opts := parseFlags(t, "-log-expand-level=warn")
+	if opts.ExpandLevel == nil || opts.ExpandLevel.Level() != slog.LevelWarn {
+		t.Errorf("ExpandLevel = %v; want %v", opts.ExpandLevel, slog.LevelWarn)
+	}
+	if opts := parseFlags(t); opts.ExpandLevel != nil {
+		t.Errorf("ExpandLevel = %v; want nil by default", opts.ExpandLevel)
+	}
+}
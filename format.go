@@ -0,0 +1,221 @@
+package humane
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// A Formatter appends a human-friendly version of v to dst and returns the
+// extended buffer. If a Formatter cannot handle v, it should return dst
+// unchanged and false. The handler then formats v in the usual way.
+//
+// The handler quotes the output of a Formatter if it needs quoting.
+type Formatter func(dst []byte, v slog.Value) ([]byte, bool)
+
+// Formatters choose a [Formatter] for the value of each Attr.
+//
+// Suffixes maps a key suffix (e.g., "_bytes") to a Formatter. The handler
+// tests suffixes against an Attr's own key, not its groups. If more than one
+// suffix matches, the longest wins.
+//
+// Kinds maps a [log/slog.Kind] to a Formatter. The handler consults Kinds
+// only if no suffix matches the key.
+type Formatters struct {
+	Suffixes map[string]Formatter
+	Kinds    map[slog.Kind]Formatter
+}
+
+type suffixFormatter struct {
+	f      Formatter
+	suffix string
+}
+
+// formatters is the handler's compiled version of Formatters.
+type formatters struct {
+	kinds    map[slog.Kind]Formatter
+	suffixes []suffixFormatter
+}
+
+func newFormatters(fs *Formatters) *formatters {
+	if fs == nil || (len(fs.Suffixes) == 0 && len(fs.Kinds) == 0) {
+		return nil
+	}
+	compiled := &formatters{kinds: fs.Kinds}
+	for suffix, f := range fs.Suffixes {
+		compiled.suffixes = append(compiled.suffixes, suffixFormatter{f, suffix})
+	}
+	// Sort longest first so that the most specific suffix wins. Break ties
+	// by the suffix itself to keep the order stable.
+	slices.SortFunc(compiled.suffixes, func(a, b suffixFormatter) int {
+		if c := cmp.Compare(len(b.suffix), len(a.suffix)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.suffix, b.suffix)
+	})
+	return compiled
+}
+
+func (fs *formatters) lookup(key string, kind slog.Kind) Formatter {
+	for _, sf := range fs.suffixes {
+		if strings.HasSuffix(key, sf.suffix) {
+			return sf.f
+		}
+	}
+	return fs.kinds[kind]
+}
+
+// appendFormatted writes val using a registered Formatter. It reports false
+// if no Formatter applies. If there is a limit on the length of the value,
+// the Formatter writes in place only up to that limit; anything longer goes to
+// a new array, of which appendFormatted copies no more than the limit.
+func (h *handler) appendFormatted(buf *buffer.Buffer, key string, val slog.Value, limit int) (handled bool) {
+	if h.formatters == nil {
+		return false
+	}
+	f := h.formatters.lookup(key, val.Kind())
+	if f == nil {
+		return false
+	}
+	start := len(*buf)
+	defer func() {
+		if r := recover(); r != nil {
+			*buf = (*buf)[:start]
+			h.appendString(buf, panicText("format", r), limit)
+			handled = true
+		}
+	}()
+	dst := *buf
+	if limit > 0 {
+		dst = dst[:start:min(cap(dst), start+limit)]
+	}
+	out, ok := f(dst, val)
+	if !ok {
+		*buf = (*buf)[:start]
+		return false
+	}
+	if limit > 0 {
+		w := newLimitWriter(buf, limit)
+		w.Write(out[start:])
+		w.cut(start)
+	} else {
+		*buf = out
+	}
+	h.quoteFrom(buf, start)
+	return true
+}
+
+// Bytes is a size in bytes. The handler always displays a Bytes value in
+// binary units (e.g., "12.3 MiB"), whether or not a [Formatter] is
+// registered for its key. Use it to mark a single Attr as a size:
+//
+//	logger.Info("Downloaded", "size", humane.Bytes(n))
+type Bytes int64
+
+// String returns b in binary units (e.g., "12.3 MiB").
+func (b Bytes) String() string {
+	return string(appendBytes(nil, float64(b)))
+}
+
+// FormatBytes is a [Formatter] that displays integer and float values as a
+// size in binary units (e.g., "12.3 MiB"). Register it for a key suffix such
+// as "_bytes".
+func FormatBytes(dst []byte, v slog.Value) ([]byte, bool) {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return appendBytes(dst, float64(v.Int64())), true
+	case slog.KindUint64:
+		return appendBytes(dst, float64(v.Uint64())), true
+	case slog.KindFloat64:
+		return appendBytes(dst, v.Float64()), true
+	default:
+		return dst, false
+	}
+}
+
+var byteUnits = [...]string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+func appendBytes(dst []byte, n float64) []byte {
+	if n < 0 {
+		dst = append(dst, '-')
+		n = -n
+	}
+	unit := 0
+	for n >= 1024 && unit < len(byteUnits)-1 {
+		n /= 1024
+		unit++
+	}
+	if unit == 0 {
+		dst = strconv.AppendFloat(dst, n, 'f', -1, 64)
+	} else {
+		dst = strconv.AppendFloat(dst, n, 'f', 1, 64)
+	}
+	dst = append(dst, ' ')
+	return append(dst, byteUnits[unit]...)
+}
+
+// FormatDuration returns a [Formatter] that rounds durations to precision
+// and drops zero trailing units (e.g., "1h2m" rather than "1h2m0.345s").
+func FormatDuration(precision time.Duration) Formatter {
+	return func(dst []byte, v slog.Value) ([]byte, bool) {
+		if v.Kind() != slog.KindDuration {
+			return dst, false
+		}
+		return appendDuration(dst, v.Duration().Round(precision)), true
+	}
+}
+
+func appendDuration(dst []byte, d time.Duration) []byte {
+	s := d.String()
+	if s == "0s" {
+		return append(dst, s...)
+	}
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return append(dst, s...)
+}
+
+// FormatNumber is a [Formatter] that displays integer and float values with
+// commas between groups of thousands (e.g., "1,073,741,824").
+func FormatNumber(dst []byte, v slog.Value) ([]byte, bool) {
+	var num []byte
+	var scratch [32]byte
+	switch v.Kind() {
+	case slog.KindInt64:
+		num = strconv.AppendInt(scratch[:0], v.Int64(), 10)
+	case slog.KindUint64:
+		num = strconv.AppendUint(scratch[:0], v.Uint64(), 10)
+	case slog.KindFloat64:
+		num = strconv.AppendFloat(scratch[:0], v.Float64(), 'f', -1, 64)
+	default:
+		return dst, false
+	}
+	return appendThousands(dst, num), true
+}
+
+func appendThousands(dst, num []byte) []byte {
+	if len(num) > 0 && num[0] == '-' {
+		dst = append(dst, '-')
+		num = num[1:]
+	}
+	digits := len(num)
+	if i := slices.IndexFunc(num, func(c byte) bool { return c < '0' || c > '9' }); i >= 0 {
+		digits = i
+	}
+	for i := 0; i < digits; i++ {
+		if i > 0 && (digits-i)%3 == 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, num[i])
+	}
+	return append(dst, num[digits:]...)
+}
@@ -0,0 +1,102 @@
+package humane_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/telemachus/humane"
+)
+
+func TestFormatters(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name string
+		want string
+		attr slog.Attr
+	}{
+		{
+			name: "bytes by suffix",
+			attr: slog.Int("body_bytes", 12897485),
+			want: ` INFO | message | body_bytes="12.3 MiB"` + "\n",
+		},
+		{
+			name: "small bytes by suffix",
+			attr: slog.Int("body_bytes", 512),
+			want: ` INFO | message | body_bytes="512 B"` + "\n",
+		},
+		{
+			name: "longest suffix wins",
+			attr: slog.Int("count_total_bytes", 2048),
+			want: ` INFO | message | count_total_bytes=2,048` + "\n",
+		},
+		{
+			name: "number by kind",
+			attr: slog.Int("count", -1073741824),
+			want: ` INFO | message | count=-1,073,741,824` + "\n",
+		},
+		{
+			name: "float by kind",
+			attr: slog.Float64("ratio", 1234.5),
+			want: ` INFO | message | ratio=1,234.5` + "\n",
+		},
+		{
+			name: "duration by kind",
+			attr: slog.Duration("elapsed", time.Hour+2*time.Minute+345*time.Millisecond),
+			want: ` INFO | message | elapsed=1h2m` + "\n",
+		},
+		{
+			name: "formatter declines",
+			attr: slog.String("size_bytes", "unknown"),
+			want: ` INFO | message | size_bytes=unknown` + "\n",
+		},
+		{
+			name: "no formatter",
+			attr: slog.Bool("ok", true),
+			want: ` INFO | message | ok=true` + "\n",
+		},
+	}
+	opts := &humane.Options{
+		ReplaceAttr: removeTime,
+		Formatters: &humane.Formatters{
+			Suffixes: map[string]humane.Formatter{
+				"_bytes":       humane.FormatBytes,
+				"_total_bytes": humane.FormatNumber,
+			},
+			Kinds: map[slog.Kind]humane.Formatter{
+				slog.KindInt64:    humane.FormatNumber,
+				slog.KindFloat64:  humane.FormatNumber,
+				slog.KindDuration: humane.FormatDuration(time.Minute),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			logger := slog.New(humane.NewHandler(&buf, opts))
+			logger.Info("message", tc.attr)
+			got := buf.String()
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBytesWrapper(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "size", humane.Bytes(3<<30), "tiny", humane.Bytes(7))
+	got := buf.String()
+	want := ` INFO | message | size="3.0 GiB" tiny="7 B"` + "\n"
+	if got != want {
+		t.Errorf("humane.Bytes = %q; want %q", got, want)
+	}
+	if s := humane.Bytes(1536).String(); s != "1.5 KiB" {
+		t.Errorf("humane.Bytes(1536).String() = %q; want %q", s, "1.5 KiB")
+	}
+}
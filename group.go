@@ -0,0 +1,136 @@
+package humane
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// A GroupStyle determines how the handler displays the keys of Attrs in
+// groups.
+type GroupStyle int
+
+const (
+	// GroupDotted joins the names of groups and the key with dots (e.g.,
+	// "req.method=GET req.path=/x"). This is the default.
+	GroupDotted GroupStyle = iota
+
+	// GroupNested displays each group once, with its members in braces
+	// (e.g., "req={method=GET path=/x headers={ua=curl}}").
+	GroupNested
+)
+
+var groupStyleNames = []string{"dotted", "nested"}
+
+// String returns the name of g.
+func (g GroupStyle) String() string {
+	return enumString(groupStyleNames, "GroupStyle", g)
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (g GroupStyle) MarshalText() ([]byte, error) {
+	return marshalEnum(groupStyleNames, "GroupStyle", g)
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (g *GroupStyle) UnmarshalText(data []byte) error {
+	return unmarshalEnum(groupStyleNames, "GroupStyle", data, g)
+}
+
+const defaultGroupSeparator = "."
+
+var errGroupSeparator = errors.New(`want text without spaces, "=", quotes, backslashes, or control characters`)
+
+// checkGroupSeparator returns an error if sep would make dotted keys
+// ambiguous: if it needs quoting, which would force every grouped key to be
+// quoted, if it holds a control character, or if it holds a backslash, which
+// EscapeKeys uses. sep must not be empty.
+func checkGroupSeparator(sep string) error {
+	if sep == "" || needsQuoting(sep) || hasControl(sep) || strings.Contains(sep, `\`) {
+		return fmt.Errorf("humane: GroupSeparator %q: %w", sep, errGroupSeparator)
+	}
+	return nil
+}
+
+// appendKeyPart writes a key or the name of a group, or its alias, escaped
+// if the handler escapes keys.
+func (h *handler) appendKeyPart(buf *buffer.Buffer, s string) {
+	s = h.alias(s)
+	if !h.escapeKeys {
+		buf.WriteString(s)
+		return
+	}
+	*buf = appendEscaped(*buf, s, h.groupSep)
+}
+
+// appendEscaped appends a key or the name of a group to dst as EscapeKeys
+// displays it, with a backslash before each sep and each backslash.
+func appendEscaped(dst []byte, s, sep string) []byte {
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], sep):
+			dst = append(dst, '\\')
+			dst = append(dst, sep...)
+			i += len(sep)
+		case s[i] == '\\':
+			dst = append(dst, `\\`...)
+			i++
+		default:
+			dst = append(dst, s[i])
+			i++
+		}
+	}
+	return dst
+}
+
+// appendNestedKey writes a key, first opening any of groups that are not yet
+// open and closing any open groups that groups does not include. groups must
+// extend or be a prefix of the open groups.
+//
+// Since the handler opens a group only when it writes the group's first
+// member, empty groups never appear. A state's opened field counts the groups
+// that are open in its buffer. A handler's preformatted Attrs may leave groups
+// open, and the handler closes them at the end of each record.
+func (h *handler) appendNestedKey(s *state, groups []string, key string) {
+	h.closeGroups(s, len(groups))
+	for _, g := range groups[s.opened:] {
+		s.appendSpace()
+		h.appendKeyText(s.buf, h.alias(g))
+		s.buf.WriteString("={")
+		s.fresh = true
+	}
+	s.opened = len(groups)
+	s.appendSpace()
+	h.appendKeyText(s.buf, h.alias(key))
+	s.buf.WriteByte('=')
+}
+
+// appendSpace writes the space before a key unless the key is the first
+// member of a group.
+func (s *state) appendSpace() {
+	if !s.fresh {
+		s.buf.WriteByte(' ')
+	}
+	s.fresh = false
+}
+
+// closeGroups closes open groups until only n remain.
+func (h *handler) closeGroups(s *state, n int) {
+	for ; s.opened > n; s.opened-- {
+		if h.groupStyle == GroupNested && !s.expand {
+			s.buf.WriteByte('}')
+		}
+	}
+}
+
+// appendKeyText writes a key or the name of a group, quoted if necessary.
+func (h *handler) appendKeyText(buf *buffer.Buffer, key string) {
+	if h.needsQuoting(key) {
+		*buf = strconv.AppendQuote(*buf, key)
+	} else {
+		buf.WriteString(key)
+	}
+}
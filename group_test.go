@@ -0,0 +1,128 @@
+package humane_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func nestedLogger(buf *bytes.Buffer) *slog.Logger {
+	opts := &humane.Options{GroupStyle: humane.GroupNested, ReplaceAttr: removeTime}
+	return slog.New(humane.NewHandler(buf, opts))
+}
+
+func TestGroupNested(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := nestedLogger(&buf)
+	logger.Info("foo",
+		slog.Group("req", "method", "GET", "path", "/x", slog.Group("headers", "ua", "curl")),
+		slog.Group("empty"),
+		"status", 200,
+	)
+	got := buf.String()
+	want := " INFO | foo | req={method=GET path=/x headers={ua=curl}} status=200\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestGroupNestedWithGroup(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := nestedLogger(&buf)
+	req := logger.With("app", "api").WithGroup("req").With("id", 1).WithGroup("user")
+	req.Info("foo", "name", "bob")
+	req.Info("bar")
+	logger.WithGroup("unused").Info("baz", "a", 1)
+	got := buf.String()
+	want := " INFO | foo | app=api req={id=1 user={name=bob}}\n" +
+		" INFO | bar | app=api req={id=1}\n" +
+		" INFO | baz | unused={a=1}\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestGroupNestedTrailingAttrs(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{GroupStyle: humane.GroupNested, MaxAttrs: 1}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.WithGroup("g").Info("foo", "a", 1, "b", 2)
+	got := buf.String()
+	want := " INFO | foo | g={a=1 …(+1 attrs)} time="
+	if !bytes.HasPrefix([]byte(got), []byte(want)) {
+		t.Errorf("got %q; want prefix %q", got, want)
+	}
+}
+
+func TestGroupSeparatorEscapeKeys(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		GroupSeparator: "/",
+		EscapeKeys:     true,
+		ReplaceAttr:    removeTime,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.WithGroup("http").Info("foo", "status", 200, "a/b", 1, `c\d`, 2)
+	logger.Info("bar", "http/status", 200)
+	got := buf.String()
+	want := ` INFO | foo | http/status=200 http/a\/b=1 http/c\\d=2` + "\n" +
+		` INFO | bar | http\/status=200` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestGroupSeparatorNoEscape(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{GroupSeparator: "::", ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo", slog.Group("a", slog.Group("b", "c.d", 1)))
+	got := buf.String()
+	want := " INFO | foo | a::b::c.d=1\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestGroupSeparatorInvalid(t *testing.T) {
+	t.Parallel()
+	for _, sep := range []string{" ", "=", `"`, `\`, "-\n-", "\t", "\u00a0"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewHandler with GroupSeparator %q did not panic", sep)
+				}
+			}()
+			humane.NewHandler(&bytes.Buffer{}, &humane.Options{GroupSeparator: sep})
+		}()
+	}
+}
+
+// testing.AllocsPerRun does not allow parallel tests.
+//
+//nolint:paralleltest
+func TestGroupedKeysAllocs(t *testing.T) {
+	one := []slog.Attr{slog.Group("g", slog.Int("a", 1))}
+	three := []slog.Attr{slog.Group("g", slog.Int("a", 1), slog.Int("b", 2), slog.Int("c", 3))}
+	for name, opts := range map[string]*humane.Options{"dotted": nil, "escaped": {EscapeKeys: true}} {
+		logger := slog.New(humane.NewHandler(io.Discard, opts))
+		want := testing.AllocsPerRun(100, func() {
+			logger.LogAttrs(context.Background(), slog.LevelInfo, "m", one...)
+		})
+		got := testing.AllocsPerRun(100, func() {
+			logger.LogAttrs(context.Background(), slog.LevelInfo, "m", three...)
+		})
+		if got > want {
+			t.Errorf("%s: three grouped keys cost %v allocations; want %v, as for one", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package humane
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/telemachus/humane/internal/buffer"
+	"github.com/telemachus/humane/internal/width"
+)
+
+// A Rule picks out records for the handler to highlight: those with an Attr,
+// or a message, whose value matches. Use [ParseRule] to create a Rule, and
+// then set Marker and Line as you like.
+//
+// In color mode, the handler highlights the matching value or message, or with
+// Line the whole first line of the record, in reverse video. Marker flags the
+// line whether or not the handler uses color.
+type Rule struct {
+	// Marker, if not empty, starts the first line of each record that the
+	// rule matches (e.g., "!!" or "▶"). Every other line, including the
+	// lines below the first in an expanded record, starts with spaces
+	// instead, so that the columns still line up.
+	Marker string
+
+	// Line highlights the whole line rather than only the value or the
+	// message that matches.
+	Line bool
+
+	key  string
+	op   ruleOp
+	text string
+	num  float64
+	re   *regexp.Regexp
+}
+
+type ruleOp int
+
+const (
+	opEq ruleOp = iota
+	opNe
+	opLt
+	opLe
+	opGt
+	opGe
+	opMatch
+)
+
+// ruleOps lists the operators so that the longer ones come before their
+// prefixes.
+var ruleOps = []struct {
+	text string
+	op   ruleOp
+}{
+	{">=", opGe},
+	{"<=", opLe},
+	{"!=", opNe},
+	{"=", opEq},
+	{">", opGt},
+	{"<", opLt},
+	{"~", opMatch},
+}
+
+// defaultMarker is the marker of rules from environment variables and flags.
+const defaultMarker = "!"
+
+// parseFlagRule parses a rule from an environment variable or a flag. Such a
+// rule has the default marker, so that it shows without color too.
+func parseFlagRule(s string) (Rule, error) {
+	r, err := ParseRule(s)
+	r.Marker = defaultMarker
+	return r, err
+}
+
+var errRule = errors.New("want KEY OP VALUE, where OP is =, !=, <, <=, >, >=, or ~")
+
+// ParseRule parses a rule of the form KEY OP VALUE, such as "status>=500",
+// "user=bob", or "msg~timeout|deadline". KEY is the full key of an Attr,
+// including groups (e.g., "req.status"), or "msg" for the message. The
+// operators are the following.
+//
+//   - = and != compare the value as text (e.g., "user=bob").
+//   - <, <=, >, and >= compare numbers. They match only numeric values and
+//     strings that parse as numbers.
+//   - ~ matches a regular expression (see [regexp/syntax]) anywhere in the
+//     value as text (e.g., "msg~(?i)timeout").
+//
+// Spaces around KEY and VALUE do not count.
+func ParseRule(s string) (Rule, error) {
+	i := strings.IndexAny(s, "=!<>~")
+	key := strings.TrimSpace(s[:max(i, 0)])
+	if i < 0 || key == "" {
+		return Rule{}, fmt.Errorf("humane: rule %q: %w", s, errRule)
+	}
+	r := Rule{key: key, op: -1}
+	rest := s[i:]
+	for _, o := range ruleOps {
+		if strings.HasPrefix(rest, o.text) {
+			r.op = o.op
+			r.text = strings.TrimSpace(rest[len(o.text):])
+			break
+		}
+	}
+	var err error
+	switch r.op {
+	case -1:
+		err = errRule
+	case opEq, opNe:
+	case opMatch:
+		r.re, err = regexp.Compile(r.text)
+	default:
+		r.num, err = strconv.ParseFloat(r.text, 64)
+	}
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) {
+			err = numErr.Err
+		}
+		return Rule{}, fmt.Errorf("humane: rule %q: %w", s, err)
+	}
+	return r, nil
+}
+
+// String returns the rule in the form that ParseRule accepts.
+func (r Rule) String() string {
+	for _, o := range ruleOps {
+		if o.op == r.op {
+			return r.key + o.text + r.text
+		}
+	}
+	return r.key
+}
+
+// matches reports whether v matches the rule.
+func (r *Rule) matches(v slog.Value) bool {
+	switch r.op {
+	case opEq:
+		return v.String() == r.text
+	case opNe:
+		return v.String() != r.text
+	case opMatch:
+		return r.re.MatchString(v.String())
+	}
+	n, ok := valueNumber(v)
+	if !ok {
+		return false
+	}
+	switch r.op {
+	case opLt:
+		return n < r.num
+	case opLe:
+		return n <= r.num
+	case opGt:
+		return n > r.num
+	default:
+		return n >= r.num
+	}
+}
+
+func valueNumber(v slog.Value) (float64, bool) {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return float64(v.Int64()), true
+	case slog.KindUint64:
+		return float64(v.Uint64()), true
+	case slog.KindFloat64:
+		return v.Float64(), true
+	case slog.KindString:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.String()), 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// A rule is a Rule with its key split into the names of groups and the key.
+type rule struct {
+	Rule
+	parts []string
+	// column is the marker padded with spaces to the width of the marker
+	// column, or nil if the rule has no marker.
+	column []byte
+}
+
+// newRules prepares the rules for a handler, and it returns the blank marker
+// column: a space for each cell of the widest marker and one more, or nothing
+// if no rule has a marker.
+func newRules(rules []Rule, sep string) ([]rule, string) {
+	compiled := make([]rule, 0, len(rules))
+	widths := make([]int, 0, len(rules))
+	markerWidth := 0
+	for _, r := range rules {
+		w := width.Bytes([]byte(r.Marker))
+		compiled = append(compiled, rule{Rule: r, parts: strings.Split(r.key, sep)})
+		widths = append(widths, w)
+		markerWidth = max(markerWidth, w)
+	}
+	if markerWidth == 0 {
+		return compiled, ""
+	}
+	for i := range compiled {
+		if m := compiled[i].Marker; m != "" {
+			pad := bytes.Repeat([]byte{' '}, markerWidth-widths[i]+1)
+			compiled[i].column = append([]byte(m), pad...)
+		}
+	}
+	return compiled, strings.Repeat(" ", markerWidth+1)
+}
+
+const highlightCode = "\x1b[7m" // reverse video
+
+// A highlight collects what the rules that match a record call for.
+type highlight struct {
+	// marker is one more than the index of the first rule with a marker
+	// that matches, or zero if none does.
+	marker int
+	line   bool
+	msg    bool
+}
+
+// add notes that the ith rule matches.
+func (hl *highlight) add(i int, r *rule) {
+	if r.Marker != "" && (hl.marker == 0 || i < hl.marker-1) {
+		hl.marker = i + 1
+	}
+	hl.line = hl.line || r.Line
+}
+
+// matchMessage applies the rules for the message.
+func (h *handler) matchMessage(hl *highlight, msg string) {
+	for i := range h.rules {
+		r := &h.rules[i]
+		if len(r.parts) == 1 && r.key == slog.MessageKey && r.matches(slog.StringValue(msg)) {
+			hl.add(i, r)
+			hl.msg = hl.msg || !r.Line
+		}
+	}
+}
+
+// matchAttr applies the rules for an Attr whose value has been written to buf
+// starting at start.
+func (h *handler) matchAttr(s *state, a slog.Attr, start int) {
+	hit := false
+	for i := range h.rules {
+		r := &h.rules[i]
+		if matchesKey(r.parts, s.groups, a.Key) && r.matches(a.Value) {
+			s.hl.add(i, r)
+			hit = hit || !r.Line
+		}
+	}
+	if hit && h.color {
+		wrapFrom(s.buf, start, highlightCode)
+	}
+}
+
+// appendMsg writes the message, highlighted if a rule calls for it.
+func (h *handler) appendMsg(buf *buffer.Buffer, msg string, hl *highlight) {
+	start := len(*buf)
+	h.appendText(buf, msg)
+	if hl.msg && h.color {
+		wrapFrom(buf, start, highlightCode)
+	}
+}
+
+// applyHighlight fills in the marker column that Handle reserves at the start
+// of buf, and it highlights the whole first line of a record if a rule calls
+// for it. The first line ends at lineEnd.
+func (h *handler) applyHighlight(buf *buffer.Buffer, lineEnd int, hl *highlight) {
+	start := len(h.blankColumn)
+	if hl.marker > 0 {
+		column := h.rules[hl.marker-1].column
+		*buf = slices.Replace(*buf, 0, start, column...)
+		lineEnd += len(column) - start
+		start = len(column)
+	}
+	if hl.line && h.color {
+		highlightLine(buf, start, lineEnd)
+	}
+}
+
+// highlightLine wraps buf[start:end] in reverse video. Each reset in the line
+// would end the highlight, so highlightLine renews it after each one. It works
+// in place: it grows buf by the bytes that it adds, and then it moves each
+// part of the line to its new place, starting from the end.
+func highlightLine(buf *buffer.Buffer, start, end int) {
+	reset := []byte(colorReset)
+	n := bytes.Count((*buf)[start:end], reset)
+	extra := (n+1)*len(highlightCode) + len(colorReset)
+	size := len(*buf)
+	*buf = slices.Grow(*buf, extra)[:size+extra]
+	copy((*buf)[end+extra:], (*buf)[end:size])
+	w := end + extra - len(colorReset)
+	copy((*buf)[w:], colorReset)
+	rest, search := end, end
+	for {
+		i := bytes.LastIndex((*buf)[start:search], reset)
+		from := start
+		if i >= 0 {
+			from = start + i + len(colorReset)
+		}
+		w -= rest - from
+		copy((*buf)[w:], (*buf)[from:rest])
+		w -= len(highlightCode)
+		copy((*buf)[w:], highlightCode)
+		if i < 0 {
+			return
+		}
+		rest, search = from, start+i
+	}
+}
@@ -0,0 +1,160 @@
+package humane_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func mustRule(t *testing.T, s, marker string) humane.Rule {
+	t.Helper()
+	r, err := humane.ParseRule(s)
+	if err != nil {
+		t.Fatalf("ParseRule(%q): %v", s, err)
+	}
+	r.Marker = marker
+	return r
+}
+
+func TestParseRule(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{"status>=500", "user=bob", "user!=", "msg~(?i)time ?out", "req.size<1e6"} {
+		r, err := humane.ParseRule(s)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", s, err)
+			continue
+		}
+		if r.String() != s {
+			t.Errorf("ParseRule(%q).String() = %q", s, r.String())
+		}
+	}
+	if r, err := humane.ParseRule(" status >= 500 "); err != nil || r.String() != "status>=500" {
+		t.Errorf("ParseRule with spaces = %v, %v; want status>=500", r, err)
+	}
+	for _, s := range []string{"", "status", ">=500", "status>=high", "msg~(", "a!b"} {
+		if _, err := humane.ParseRule(s); err == nil {
+			t.Errorf("ParseRule(%q): got nil error", s)
+		}
+	}
+}
+
+func TestHighlightMarker(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr: removeTime,
+		Highlight: []humane.Rule{
+			mustRule(t, "status>=500", "‼"),
+			mustRule(t, "msg~timeout", "⏱⏱"),
+			mustRule(t, "req.user=bob", ""),
+		},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("ok", "status", 200)
+	logger.Info("bad", "status", 503)
+	logger.Info("bad", "status", "504")
+	logger.Info("read timeout")
+	logger.Info("both timeout", "status", 500)
+	logger.Info("grouped", slog.Group("req", "user", "bob"))
+	want := "    INFO | ok | status=200\n" +
+		"‼   INFO | bad | status=503\n" +
+		"‼   INFO | bad | status=504\n" +
+		"⏱⏱  INFO | read timeout |\n" +
+		"‼   INFO | both timeout | status=500\n" +
+		"    INFO | grouped | req.user=bob\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHighlightColor(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	line := mustRule(t, "level=ERROR", "")
+	line.Line = true
+	opts := &humane.Options{
+		Color:       humane.Always,
+		ReplaceAttr: removeTime,
+		Highlight: []humane.Rule{
+			mustRule(t, "status>=500", ""),
+			mustRule(t, "msg~timeout", ""),
+			line,
+		},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("read timeout", "status", 503, "other", 1)
+	logger.Info("fine", "level", "ERROR")
+	got := strings.Split(buf.String(), "\n")
+	want := []string{
+		"\x1b[32m INFO\x1b[0m | \x1b[7mread timeout\x1b[0m | status=\x1b[7m503\x1b[0m other=1",
+		"\x1b[7m\x1b[32m INFO\x1b[0m\x1b[7m | fine | level=ERROR\x1b[0m",
+		"",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: got %q; want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestHighlightWithAttrs(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr: removeTime,
+		Highlight:   []humane.Rule{mustRule(t, "tenant=acme", "*")},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.With("tenant", "acme").Info("one")
+	logger.With("tenant", "other").Info("two")
+	want := "*  INFO | one | tenant=acme\n" +
+		"   INFO | two | tenant=other\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestHighlightMarkerExpanded(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ExpandLevel: slog.LevelWarn,
+		ReplaceAttr: removeTime,
+		Highlight:   []humane.Rule{mustRule(t, "status>=500", "!!")},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Warn("m", "status", 503)
+	logger.Warn("n", "status", 200)
+	want := "!!  WARN | m |\n       status: 503\n" +
+		"    WARN | n |\n       status: 200\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHighlightLineMarker(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	r := mustRule(t, "status>=500", "▶")
+	r.Line = true
+	opts := &humane.Options{
+		Color:       humane.Always,
+		ExpandLevel: slog.LevelError,
+		ReplaceAttr: removeTime,
+		Highlight:   []humane.Rule{r},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Warn("m", "status", 503)
+	logger.Error("n", "status", 503)
+	want := "▶ \x1b[7m\x1b[33m WARN\x1b[0m\x1b[7m | m | status=503\x1b[0m\n" +
+		"▶ \x1b[7m\x1b[1;31mERROR\x1b[0m\x1b[7m | n |\x1b[0m\n      status: 503\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -3,14 +3,14 @@ package humane
 import (
 	"context"
 	"encoding"
-	"fmt"
 	"io"
 	"log/slog"
-	"runtime"
+	"maps"
+	"os"
 	"slices"
 	"strconv"
-	"strings"
 	"sync"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
@@ -21,22 +21,60 @@ var (
 	defaultLevel      = slog.LevelInfo
 	defaultTimeFormat = "2006-01-02T03:04.05 MST"
 	levelValues       = map[slog.Level]string{
-		slog.LevelDebug: "DEBUG |",
-		slog.LevelInfo:  " INFO |",
-		slog.LevelWarn:  " WARN |",
-		slog.LevelError: "ERROR |",
+		LevelTrace:      "TRACE",
+		slog.LevelDebug: "DEBUG",
+		slog.LevelInfo:  " INFO",
+		slog.LevelWarn:  " WARN",
+		slog.LevelError: "ERROR",
 	}
 )
 
 type handler struct {
-	w           io.Writer
-	level       slog.Leveler
-	mu          *sync.Mutex
-	replaceAttr func(groups []string, a slog.Attr) slog.Attr
-	attrs       string
-	timeFormat  string
-	groups      []string
-	addSource   bool
+	w             io.Writer
+	level         slog.Leveler
+	expandLevel   slog.Leveler
+	mu            *sync.Mutex
+	out           *output
+	replaceAttr   func(groups []string, a slog.Attr) slog.Attr
+	formatters    *formatters
+	attrs         string
+	fields        []field
+	nattrs        int
+	dropped       int
+	timeFormat    string
+	last          *lastTime
+	sources       *sources
+	aliases       map[string]string
+	groups        []string
+	priorityKeys  []string
+	colorKeys     [][]string
+	rules         []rule
+	hl            highlight
+	layout        []layoutPart
+	goas          []groupOrAttrs
+	timeMode      TimeMode
+	anyMode       AnyMode
+	maxAnyDepth   int
+	maxAnyEntries int
+	maxValueLen   int
+	maxLineLen    int
+	maxAttrs      int
+	blankColumn   string
+	abbreviateIDs int
+	duplicates    DuplicatePolicy
+	groupStyle    GroupStyle
+	groupSep      string
+	openGroups    int
+	linkFormat    string
+	sourceMode    SourceMode
+	addSource     bool
+	escapeKeys    bool
+	sortKeys      bool
+	sanitize      bool
+	color         bool
+	sourceField   bool
+	timeField     bool
+	links         bool
 }
 
 // Options are options for Humane's [log/slog.Handler].
@@ -50,7 +88,9 @@ type handler struct {
 // to Attrs. Note: Humane's handler does not apply ReplaceAttr to the level or
 // message Attrs because the handler already formats these items in a specific
 // way. However, Humane does apply ReplaceAttr to the time Attr (unless it's
-// zero) and to the source Attr if AddSource is true.
+// zero) and to the source Attr if AddSource is true. With AnyFlatten,
+// ReplaceAttr receives a composite value before the handler flattens it, and
+// then each of the flattened Attrs.
 //
 // TimeFormat defaults to "2006-01-02T03:04.05 MST". Set a format option to
 // customize the presentation of the time. (See [time.Time.Format] for details
@@ -59,11 +99,209 @@ type handler struct {
 // AddSource defaults to false. If AddSource is true, the handler adds to each
 // log event an Attr with [log/slog.SourceKey] as the key and "file:line" as
 // the value.
+//
+// SourceMode defaults to SourceFull, which displays the absolute path of the
+// file. SourceBase displays only the file name, SourceRelative displays the
+// path relative to the root of the file's module, and SourceFunc displays the
+// name of the function instead of the file and line. (See [SourceMode] for
+// details.) SourceColumn defaults to false. If SourceColumn is true, the
+// handler displays the source as its own column between the level and the
+// message rather than as an Attr. Neither option has any effect unless
+// AddSource is true.
+//
+// Terminal defaults to Auto, which means that the handler checks whether its
+// writer is a terminal. Set Terminal to Always or Never to override that
+// check (e.g., when you pipe output to "less -R"). Several other options
+// depend on whether the writer is a terminal.
+//
+// Sanitize defaults to Auto, which means that the handler sanitizes its output
+// if its writer is a terminal. Sanitized output shows control characters and
+// invalid UTF-8 as escapes (e.g., "\x1b" or "\r"), so that a message or value
+// from user input cannot move the cursor, change colors, or forge log lines.
+// Keys and values with such characters are quoted, and the message is
+// escaped in place. Set Sanitize to Always to sanitize output to files too.
+//
+// Color defaults to Auto, which means that the handler colors its output if
+// its writer is a terminal and the NO_COLOR environment variable is unset or
+// empty. Set Color to Always to color output even to files or when NO_COLOR
+// is set, or to Never to turn color off. In color mode, the handler colors
+// the level: faint for trace, blue for debug, green for info, yellow for warn,
+// and bold red for error.
+//
+// ColorKeys defaults to nil. In color mode, the handler colors each value of
+// a key in ColorKeys (e.g., "request_id" or "req.id") with a color that it
+// derives from the value, so that every line about the same request or
+// connection has the same color. The colors come from a palette of teals,
+// purples, and pinks, which stand apart from the level colors. ColorKeys
+// names keys by their original names, and it has no effect without color.
+//
+// Highlight defaults to nil. It holds rules (see [Rule] and [ParseRule]) that
+// pick out records to highlight, such as those with "status>=500" or with a
+// message that matches "msg~timeout". In color mode, the handler shows the
+// matching value or message, or with Rule.Line the whole first line, in
+// reverse video. A rule with a Marker puts the marker at the start of the
+// first line of each record that it matches, even without color, and the
+// handler then starts every other line, including the lines of expanded
+// records, with spaces so that the columns still line up. The marker column
+// counts toward MaxLineLen.
+//
+// SourceLinks defaults to false. If SourceLinks is true and the writer is a
+// terminal, the handler displays the source as a link (an OSC 8 hyperlink)
+// to the file and line. The link's text is short: SourceFull displays only the
+// file name and line, since the link carries the full path. SourceLinkFormat
+// is a template for the URL. The handler replaces "{path}" in the template
+// with the absolute path of the file and "{line}" with the line number. It
+// defaults to "file://{path}", but an editor's URL scheme such as
+// "vscode://file{path}:{line}" may be more useful.
+//
+// Layout defaults to the empty string, which means "{level} | {msg} |
+// {attrs}". A Layout is a template for the whole line with fields such as
+// {time}, {level}, {source}, {msg}, and {attrs}. (See [Layout] for details.)
+// TimePlacement and SourceColumn are shorthands that choose a layout with the
+// time first or with a source column, and they have no effect if Layout is
+// set. NewHandler panics if Layout is invalid.
+//
+// ExpandLevel defaults to nil. If set, the handler expands each record at or
+// above ExpandLevel: it displays the record's first line without Attrs and
+// then each Attr on its own indented line as "key: value". Groups become
+// indented blocks. Expanded records include the Attrs from WithAttrs.
+// MaxLineLen applies to each line of an expanded record, and DuplicateKeys,
+// SortKeys, and PriorityKeys apply to its Attrs, keeping the members of a
+// group under the group's name.
+//
+// TimeMode defaults to TimeAbsolute, which displays the time using
+// TimeFormat. The other modes display the time elapsed since the program
+// started, the time elapsed since the previous record, or only the time of
+// day. (See [TimeMode] for details.) TimeFormat does not affect these modes.
+//
+// TimePlacement defaults to TimeTrailing, which adds the time as the last Attr
+// of the record. TimeLeading instead displays the time (without a key) as the
+// first column of the record, before the level. The column is padded to the
+// widest time that TimeMode and TimeFormat can produce, so that the level
+// column stays in line. (See [Layout] for the limits of that width.)
+//
+// Formatters defaults to nil. Set Formatters to display the values of some
+// Attrs in a more readable way, chosen by key suffix or by kind (e.g., sizes
+// as "12.3 MiB" or numbers as "1,073,741,824"). The package provides
+// [FormatBytes], [FormatDuration], and [FormatNumber], but any [Formatter]
+// will do. (To mark a single value as a size, use [Bytes] instead.)
+//
+// AnyMode defaults to AnyDefault, which displays maps, slices, and structs
+// with [fmt.Sprint]. AnyFlatten displays them as dotted keys (e.g.,
+// "user.id=7 user.name=bob"), and AnyJSON displays them as compact JSON. (See
+// [AnyMode] for details.) ReplaceAttr sees a composite value before
+// AnyFlatten flattens it, so a ReplaceAttr that redacts by key still works.
+// MaxAnyDepth limits how deeply either mode descends into nested values, and
+// MaxAnyEntries limits how many fields, elements, or map entries either mode
+// displays for one value. The handler marks anything it leaves out with "…".
+// If these limits are zero, the handler uses 4 and 32.
+//
+// MaxValueLen, MaxLineLen, and MaxAttrs default to zero, which means no
+// limit. MaxValueLen limits the length in bytes of any single value; the
+// handler cuts longer values short and adds a marker such as "…(+1234
+// bytes)". MaxLineLen limits the length in bytes of a whole line; the handler
+// stops adding Attrs once a line is too long, cuts it short, and adds
+// "…(truncated)". On such a line, no value is longer than MaxLineLen either.
+// MaxAttrs limits the number of Attrs in a record, counting those added by
+// WithAttrs but not the source or time Attrs; the handler leaves out any
+// extra Attrs and adds a marker such as "…(+3 attrs)".
+//
+// GroupStyle defaults to GroupDotted, which displays the key of an Attr in a
+// group with the names of its groups (e.g., "req.method=GET req.path=/x").
+// GroupNested instead displays each group once, with its members in braces
+// (e.g., "req={method=GET path=/x}"). GroupNested applies to groups from
+// WithGroup as well as to group Attrs.
+//
+// GroupSeparator defaults to ".". It is the text that GroupDotted puts between
+// the names of groups and the key. NewHandler panics if GroupSeparator holds
+// a space, "=", a quote, a backslash, or a control character. EscapeKeys
+// defaults to false. If EscapeKeys is true, the handler puts a backslash
+// before each separator and each backslash within a key or the name of a
+// group, so that a key such as "http.status" (displayed as "http\.status")
+// does not look like the key "status" in the group "http".
+//
+// DuplicateKeys defaults to DuplicateKeep, which displays every Attr even if
+// a key appears more than once in a record. DuplicateLastWins displays only
+// the last Attr with a given key, and DuplicateFirstWins displays only the
+// first. The policy compares full keys, including groups, and it covers Attrs
+// from WithAttrs as well as those of the record. It does not cover the source
+// or time Attrs, and it has no effect with GroupNested, except in expanded
+// records.
+//
+// SortKeys defaults to false, which displays Attrs in the order they arrive:
+// those from WithAttrs, then those of the record. If SortKeys is true, the
+// handler sorts the Attrs by key, so that a key sits in the same place on
+// every line and log files diff cleanly. PriorityKeys defaults to nil. The
+// handler displays Attrs whose keys appear in PriorityKeys first, in the order
+// of PriorityKeys, and then the others, sorted or in their original order.
+// Both options compare full keys (e.g., "req.id"). The source and time Attrs
+// stay last, and neither option has any effect with GroupNested, except in
+// expanded records.
+//
+// KeyAliases defaults to nil. It maps keys to shorter aliases for display
+// (e.g., "request_id" to "rid"). The handler replaces each key and each name
+// of a group that has an alias, so an alias applies within groups too. Each
+// alias must be unique and must not be a key that the program also uses, so
+// that a tool can undo the aliases with [KeyExpander]. NewHandler panics if
+// two keys share an alias, or if an alias is empty, holds the GroupSeparator,
+// or is itself a key with an alias. PriorityKeys, like the rest of Options,
+// names keys by their original names.
+//
+// AbbreviateIDs defaults to zero, which displays values in full. If it is
+// positive, the handler shortens string values that look like identifiers,
+// such as UUIDs and trace IDs, to their first AbbreviateIDs characters
+// followed by "…". A value looks like an identifier if it is a UUID, or if
+// it has at least 16 hexadecimal digits, at least one of them a letter, and
+// nothing else but hyphens. Long decimal numbers, such as timestamps, are
+// displayed in full.
+//
+// OnWriteError defaults to nil. If set, the handler calls it with a
+// [*WriteError] when it cannot write a record, since [log/slog.Logger]
+// discards the errors that handlers return. To avoid a flood of reports, the
+// handler calls OnWriteError at most once per ErrorInterval, which defaults to
+// one minute, and counts the failures it does not report. Fallback defaults to
+// nil. If set, the handler writes any record that its writer fails to write
+// to Fallback instead (e.g., to [os.Stderr]). WriteRetries defaults to zero.
+// It sets how many times the handler tries to write the rest of a record
+// after a short write. Use [LastWriteError] to check whether the most recent
+// write failed (e.g., in a health check).
 type Options struct {
-	Level       slog.Leveler
-	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
-	TimeFormat  string
-	AddSource   bool
+	Level            slog.Leveler
+	ExpandLevel      slog.Leveler
+	ReplaceAttr      func(groups []string, a slog.Attr) slog.Attr
+	TimeFormat       string
+	TimeMode         TimeMode
+	TimePlacement    TimePlacement
+	Formatters       *Formatters
+	AnyMode          AnyMode
+	MaxAnyDepth      int
+	MaxAnyEntries    int
+	MaxValueLen      int
+	MaxLineLen       int
+	MaxAttrs         int
+	AbbreviateIDs    int
+	DuplicateKeys    DuplicatePolicy
+	GroupStyle       GroupStyle
+	GroupSeparator   string
+	PriorityKeys     []string
+	KeyAliases       map[string]string
+	ColorKeys        []string
+	Highlight        []Rule
+	OnWriteError     func(err error)
+	Fallback         io.Writer
+	ErrorInterval    time.Duration
+	WriteRetries     int
+	SourceMode       SourceMode
+	SourceLinkFormat string
+	Layout           Layout
+	Terminal         When
+	Sanitize         When
+	Color            When
+	AddSource        bool
+	EscapeKeys       bool
+	SortKeys         bool
+	SourceColumn     bool
+	SourceLinks      bool
 }
 
 // NewHandler returns a [log/slog.Handler] using the receiver's options.
@@ -73,13 +311,45 @@ func NewHandler(w io.Writer, opts *Options) slog.Handler {
 		opts = &Options{}
 	}
 	h := &handler{
-		w:           w,
-		mu:          &sync.Mutex{},
-		level:       opts.Level,
-		timeFormat:  opts.TimeFormat,
-		replaceAttr: opts.ReplaceAttr,
-		addSource:   opts.AddSource,
+		w:             w,
+		mu:            &sync.Mutex{},
+		out:           newOutput(opts),
+		level:         opts.Level,
+		expandLevel:   opts.ExpandLevel,
+		timeFormat:    opts.TimeFormat,
+		timeMode:      opts.TimeMode,
+		last:          &lastTime{},
+		sources:       &sources{m: map[uintptr]source{}},
+		replaceAttr:   opts.ReplaceAttr,
+		formatters:    newFormatters(opts.Formatters),
+		anyMode:       opts.AnyMode,
+		maxAnyDepth:   opts.MaxAnyDepth,
+		maxAnyEntries: opts.MaxAnyEntries,
+		maxValueLen:   opts.MaxValueLen,
+		maxLineLen:    opts.MaxLineLen,
+		maxAttrs:      opts.MaxAttrs,
+		abbreviateIDs: opts.AbbreviateIDs,
+		aliases:       maps.Clone(opts.KeyAliases),
+		duplicates:    opts.DuplicateKeys,
+		groupStyle:    opts.GroupStyle,
+		groupSep:      opts.GroupSeparator,
+		escapeKeys:    opts.EscapeKeys,
+		sortKeys:      opts.SortKeys,
+		addSource:     opts.AddSource,
+		sourceMode:    opts.SourceMode,
+		linkFormat:    opts.SourceLinkFormat,
+	}
+	layout, err := parseLayout(layoutFor(opts))
+	if err != nil {
+		panic(err)
 	}
+	h.layout = layout
+	h.timeField = hasField(layout, fieldTime)
+	h.sourceField = hasField(layout, fieldSource)
+	terminal := opts.Terminal.enabled(isTerminal(w))
+	h.links = opts.SourceLinks && terminal
+	h.sanitize = opts.Sanitize.enabled(terminal)
+	h.color = opts.Color.enabled(terminal) && (opts.Color == Always || os.Getenv("NO_COLOR") == "")
 	h.groups = make([]string, 0, 10)
 	if opts.Level == nil {
 		h.level = defaultLevel
@@ -87,6 +357,34 @@ func NewHandler(w io.Writer, opts *Options) slog.Handler {
 	if h.timeFormat == "" {
 		h.timeFormat = defaultTimeFormat
 	}
+	for i := range h.layout {
+		if h.layout[i].field == fieldTime {
+			h.layout[i].min = h.timeWidth(h.layout[i].arg)
+		}
+	}
+	if h.groupSep == "" {
+		h.groupSep = defaultGroupSeparator
+	}
+	if err := checkGroupSeparator(h.groupSep); err != nil {
+		panic(err)
+	}
+	if err := checkAliases(h.aliases, h.groupSep); err != nil {
+		panic(err)
+	}
+	h.colorKeys = splitColorKeys(opts.ColorKeys, h.groupSep)
+	h.rules, h.blankColumn = newRules(opts.Highlight, h.groupSep)
+	for _, k := range opts.PriorityKeys {
+		h.priorityKeys = append(h.priorityKeys, h.aliasFull(k))
+	}
+	if h.linkFormat == "" {
+		h.linkFormat = defaultSourceLinkFormat
+	}
+	if h.maxAnyDepth <= 0 {
+		h.maxAnyDepth = defaultMaxAnyDepth
+	}
+	if h.maxAnyEntries <= 0 {
+		h.maxAnyEntries = defaultMaxAnyEntries
+	}
 	return h
 }
 
@@ -104,34 +402,20 @@ func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
 func (h *handler) Handle(_ context.Context, r slog.Record) error {
 	buf := buffer.New()
 	defer buf.Free()
-	h.appendLevel(buf, r.Level)
-	buf.WriteByte(' ')
-	buf.WriteString(r.Message)
-	buf.WriteString(" |")
-	if h.attrs != "" {
-		buf.WriteString(h.attrs)
-	}
-	r.Attrs(func(a slog.Attr) bool {
-		h.appendAttr(buf, a)
-		return true
-	})
-	if h.addSource && r.PC != 0 {
-		sourceAttr := h.newSourceAttr(r.PC)
-		h.appendAttr(buf, sourceAttr)
-	}
-	timeAttr := slog.Time(slog.TimeKey, r.Time)
-	if h.replaceAttr != nil {
-		timeAttr = h.replaceAttr(nil, timeAttr)
-	}
-	if !r.Time.IsZero() && !timeAttr.Equal(slog.Attr{}) {
-		appendKey(buf, nil, timeAttr.Key)
-		h.appendVal(buf, timeAttr.Value)
+	// Reserve the marker column, which applyHighlight fills in.
+	buf.WriteString(h.blankColumn)
+	timeAttr, _ := h.timeAttr(r.Time)
+	hl := h.hl
+	h.matchMessage(&hl, r.Message)
+	h.appendLayout(buf, &r, timeAttr, &hl)
+	h.truncateLine(buf, 0)
+	lineEnd := len(*buf)
+	if h.expands(r.Level) {
+		h.appendBlock(buf, &r, timeAttr, &hl)
 	}
+	h.applyHighlight(buf, lineEnd, &hl)
 	buf.WriteByte('\n')
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	_, err := h.w.Write(*buf)
-	return err
+	return h.write(*buf)
 }
 
 // WithAttrs returns a new [log/slog.Handler] that has the receiver's
@@ -143,10 +427,25 @@ func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	h2 := h.clone()
 	buf := buffer.New()
 	defer buf.Free()
+	st := h2.newState(buf)
+	s := &st
+	// Start from the preformatted Attrs, so that a duplicate key in attrs
+	// can replace one of them.
+	buf.WriteString(h2.attrs)
+	if h2.trackFields() {
+		h2.startFields(s, 0)
+	}
+	s.hl = h2.hl
 	for _, a := range attrs {
-		h2.appendAttr(buf, a)
+		h2.appendAttr(s, a)
+	}
+	h2.fields = slices.Clone(s.fields)
+	h2.attrs = string(*buf)
+	h2.nattrs, h2.dropped, h2.openGroups = s.nattrs, s.dropped, s.opened
+	h2.hl = s.hl
+	if h2.expandLevel != nil {
+		h2.goas = append(slices.Clip(h2.goas), groupOrAttrs{attrs: slices.Clone(attrs)})
 	}
-	h2.attrs += string(*buf)
 	return h2
 }
 
@@ -158,76 +457,171 @@ func (h *handler) WithGroup(name string) slog.Handler {
 	}
 	h2 := h.clone()
 	h2.groups = append(h2.groups, name)
+	if h2.expandLevel != nil {
+		h2.goas = append(slices.Clip(h2.goas), groupOrAttrs{group: name})
+	}
 	return h2
 }
 
 func (h *handler) clone() *handler {
-	return &handler{
-		w:           h.w,
-		mu:          h.mu,
-		level:       h.level,
-		groups:      slices.Clip(h.groups),
-		attrs:       h.attrs,
-		timeFormat:  h.timeFormat,
-		replaceAttr: h.replaceAttr,
-		addSource:   h.addSource,
-	}
+	h2 := *h
+	h2.groups = slices.Clip(h.groups)
+	return &h2
 }
 
 func (h *handler) appendLevel(buf *buffer.Buffer, level slog.Level) {
+	if h.color {
+		buf.WriteString(levelColor(level))
+		defer buf.WriteString(colorReset)
+	}
 	if lVal, ok := levelValues[level.Level()]; ok {
 		buf.WriteString(lVal)
 		return
 	}
 	buf.WriteByte(' ')
-	buf.WriteString(level.Level().String())
-	buf.WriteString(" |")
+	buf.WriteString(levelName(level.Level()))
 }
 
-func (h *handler) appendAttr(buf *buffer.Buffer, a slog.Attr) {
-	a.Value = a.Value.Resolve()
+// state holds what the handler needs to track while it formats the Attrs of
+// a single record or a single call to WithAttrs.
+type state struct {
+	buf     *buffer.Buffer
+	groups  []string
+	fields  []field
+	block   []blockField
+	hl      highlight
+	nattrs  int
+	dropped int
+	opened  int
+	line    int
+	fresh   bool
+	count   bool
+	track   bool
+	expand  bool
+}
+
+func (h *handler) newState(buf *buffer.Buffer) state {
+	return state{
+		buf:     buf,
+		groups:  slices.Clip(h.groups),
+		nattrs:  h.nattrs,
+		dropped: h.dropped,
+		opened:  h.openGroups,
+		count:   true,
+	}
+}
+
+func (h *handler) appendAttr(s *state, a slog.Attr) {
+	a = h.replace(s, a)
 	if a.Value.Kind() == slog.KindGroup {
 		attrs := a.Value.Group()
 		if len(attrs) == 0 {
 			return
 		}
 		if a.Key != "" {
-			h.groups = append(h.groups, a.Key)
+			s.groups = append(s.groups, a.Key)
 		}
 		for _, a := range attrs {
-			h.appendAttr(buf, a)
+			h.appendAttr(s, a)
 		}
 		if a.Key != "" {
-			h.groups = h.groups[:len(h.groups)-1]
+			s.groups = s.groups[:len(s.groups)-1]
+			h.closeGroups(s, len(s.groups))
 		}
 		return
 	}
-	if h.replaceAttr != nil {
-		a = h.replaceAttr(h.groups, a)
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if s.count && !h.repeatsKey(s, a.Key) {
+		if h.maxAttrs > 0 && s.nattrs >= h.maxAttrs {
+			s.dropped++
+			return
+		}
+		s.nattrs++
+	}
+	start := len(*s.buf)
+	h.appendStateKey(s, s.groups, a.Key)
+	sep := len(*s.buf) - 1
+	h.appendVal(s.buf, a.Key, a.Value, h.valueLimit())
+	if h.color && h.colorsValue(s.groups, a.Key) {
+		colorFrom(s.buf, sep+1)
 	}
-	if !a.Equal(slog.Attr{}) {
-		appendKey(buf, h.groups, a.Key)
-		h.appendVal(buf, a.Value)
+	if len(h.rules) > 0 {
+		h.matchAttr(s, a, sep+1)
 	}
+	h.recordField(s, start, sep, a.Key)
 }
 
-func appendKey(buf *buffer.Buffer, groups []string, key string) {
+// replace resolves the value of a, applies ReplaceAttr if a is not a group,
+// and then, with AnyFlatten, turns a composite value into a group. Since
+// ReplaceAttr sees the Attr before it is flattened, a ReplaceAttr that
+// matches the key of a composite value still works. The members of the
+// group then pass through ReplaceAttr in turn, like those of any group.
+func (h *handler) replace(s *state, a slog.Attr) slog.Attr {
+	a.Value = resolve(a.Value)
+	if h.replaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.replaceAttr(s.groups, a)
+		a.Value = resolve(a.Value)
+	}
+	if h.anyMode == AnyFlatten && a.Value.Kind() == slog.KindAny {
+		if v, ok := h.composite(a.Value.Any()); ok {
+			a.Value = jsonValue(v)
+		}
+	}
+	return a
+}
+
+// appendStateKey writes a key in the style that the handler and the state
+// call for: dotted, nested, or, for an expanded record, on a new line below
+// any groups that it has not yet written.
+func (h *handler) appendStateKey(s *state, groups []string, key string) {
+	if !s.expand {
+		if h.groupStyle == GroupNested {
+			h.appendNestedKey(s, groups, key)
+		} else {
+			h.appendKey(s.buf, groups, key)
+		}
+		return
+	}
+	for i := s.opened; i < len(groups); i++ {
+		h.appendBlockGroup(s.buf, i, groups[i])
+	}
+	s.opened = len(groups)
+	s.line = len(*s.buf) + 1
+	h.appendBlockKey(s.buf, len(groups), key)
+	s.buf.WriteString(": ")
+}
+
+// appendKey writes a dotted key. It writes the groups, the separators, and
+// the key straight into buf and then quotes them in place if they need it,
+// so that grouped keys cost no allocation.
+func (h *handler) appendKey(buf *buffer.Buffer, groups []string, key string) {
 	buf.WriteByte(' ')
-	if len(groups) > 0 {
-		key = strings.Join(groups, ".") + "." + key
+	if len(groups) == 0 && !h.escapeKeys {
+		h.appendKeyText(buf, h.alias(key))
+		buf.WriteByte('=')
+		return
 	}
-	if needsQuoting(key) {
-		*buf = strconv.AppendQuote(*buf, key)
-	} else {
-		buf.WriteString(key)
+	start := len(*buf)
+	for _, g := range groups {
+		h.appendKeyPart(buf, g)
+		buf.WriteString(h.groupSep)
 	}
+	h.appendKeyPart(buf, key)
+	h.quoteFrom(buf, start)
 	buf.WriteByte('=')
 }
 
-func (h *handler) appendVal(buf *buffer.Buffer, val slog.Value) {
+// appendVal writes val, cut short if it is longer than limit. A limit of zero
+// or less means no limit.
+func (h *handler) appendVal(buf *buffer.Buffer, key string, val slog.Value, limit int) {
+	if h.appendFormatted(buf, key, val, limit) {
+		return
+	}
 	switch val.Kind() {
 	case slog.KindString:
-		appendString(buf, val.String())
+		h.appendString(buf, val.String(), limit)
 	case slog.KindInt64:
 		*buf = strconv.AppendInt(*buf, val.Int64(), 10)
 	case slog.KindUint64:
@@ -237,7 +631,7 @@ func (h *handler) appendVal(buf *buffer.Buffer, val slog.Value) {
 	case slog.KindBool:
 		*buf = strconv.AppendBool(*buf, val.Bool())
 	case slog.KindDuration:
-		appendString(buf, val.Duration().String())
+		h.appendString(buf, val.Duration().String(), limit)
 	case slog.KindTime:
 		// If fmt contains any quote characters, this won't
 		// properly quote it. But alternative versions run far slower.
@@ -252,37 +646,57 @@ func (h *handler) appendVal(buf *buffer.Buffer, val slog.Value) {
 			buf.WriteByte('"')
 		}
 	case slog.KindAny, slog.KindGroup, slog.KindLogValuer:
+		if b, ok := val.Any().(Bytes); ok {
+			start := len(*buf)
+			*buf = appendBytes(*buf, float64(b))
+			h.quoteFrom(buf, start)
+			return
+		}
 		if tm, ok := val.Any().(encoding.TextMarshaler); ok {
-			data, err := tm.MarshalText()
-			if err != nil {
-				// TODO: should this append an error?
+			h.appendMarshaled(buf, tm, limit)
+			return
+		}
+		if h.anyMode == AnyJSON {
+			if v, ok := h.composite(val.Any()); ok {
+				start := len(*buf)
+				w := newLimitWriter(buf, limit)
+				h.appendJSON(&w, v)
+				w.cut(start)
+				h.quoteFrom(buf, start)
 				return
 			}
-			appendString(buf, string(data))
-			return
 		}
-		appendString(buf, fmt.Sprint(val.Any()))
+		h.appendSprint(buf, val.Any(), limit)
 	}
 }
 
-func appendString(buf *buffer.Buffer, s string) {
-	if needsQuoting(s) {
+func (h *handler) appendString(buf *buffer.Buffer, s string, limit int) {
+	s = h.abbreviate(s)
+	if limit > 0 && len(s) > limit {
+		start := len(*buf)
+		cut := runeStart(s, limit)
+		buf.WriteString(s[:cut])
+		appendTruncated(buf, len(s)-cut)
+		h.quoteFrom(buf, start)
+		return
+	}
+	h.quoteString(buf, s)
+}
+
+// quoteString writes s, quoted if necessary.
+func (h *handler) quoteString(buf *buffer.Buffer, s string) {
+	if h.needsQuoting(s) {
 		*buf = strconv.AppendQuote(*buf, s)
 	} else {
 		buf.WriteString(s)
 	}
 }
 
-func (h *handler) newSourceAttr(pc uintptr) slog.Attr {
-	source := frame(pc)
-	info := fmt.Sprintf("%s:%d", source.File, source.Line)
-	return slog.String(slog.SourceKey, info)
-}
-
-func frame(pc uintptr) runtime.Frame {
-	fs := runtime.CallersFrames([]uintptr{pc})
-	f, _ := fs.Next()
-	return f
+// quoteFrom quotes the bytes that follow start in buf if they need quoting.
+func (h *handler) quoteFrom(buf *buffer.Buffer, start int) {
+	if s := string((*buf)[start:]); h.needsQuoting(s) {
+		*buf = strconv.AppendQuote((*buf)[:start], s)
+	}
 }
 
 func needsQuoting(s string) bool {
@@ -0,0 +1,15 @@
+// Package sourcetest logs from a file below the root of the module, so that
+// tests can tell a path relative to the module root from a base name.
+package sourcetest
+
+import (
+	"log/slog"
+	"runtime"
+)
+
+// Info logs msg with logger and returns the line of the call.
+func Info(logger *slog.Logger, msg string) int {
+	_, _, line, _ := runtime.Caller(0)
+	logger.Info(msg)
+	return line + 1
+}
@@ -0,0 +1,173 @@
+//go:build ignore
+
+// Gen writes tables.go from EastAsianWidth.txt and DerivedGeneralCategory.txt,
+// two files of the Unicode Character Database that are checked in under ucd,
+// so that generating the tables needs no network and always gives the same
+// result. The files must be the official ones from unicode.org, with the
+// Unicode license header, and for the version of Unicode that package unicode
+// of the Go running gen uses, so that the widths agree with the rest of Go's
+// view of Unicode. To move to a new version, replace the files with those of
+// the new version from https://www.unicode.org/Public/VERSION/ucd/.
+//
+//	go run gen.go [-ucd dir]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	ucd := flag.String("ucd", "ucd", "`directory` that holds the UCD files")
+	flag.Parse()
+
+	wide := make(map[rune]bool)
+	version, err := readUCD(*ucd, "EastAsianWidth", func(lo, hi rune, fields []string) {
+		if w := fields[1]; w == "W" || w == "F" {
+			for r := lo; r <= hi; r++ {
+				wide[r] = true
+			}
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	zero := make(map[rune]bool)
+	gcVersion, err := readUCD(*ucd, "DerivedGeneralCategory", func(lo, hi rune, fields []string) {
+		switch fields[1] {
+		case "Mn", "Me", "Cf", "Cc":
+			for r := lo; r <= hi; r++ {
+				zero[r] = true
+			}
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if gcVersion != version {
+		log.Fatalf("EastAsianWidth.txt is for Unicode %s, but DerivedGeneralCategory.txt is for %s", version, gcVersion)
+	}
+	if version != unicode.Version {
+		log.Fatalf("the UCD files are for Unicode %s, but package unicode uses %s; get the files from %s", version, unicode.Version, ucdURL(unicode.Version))
+	}
+	// A soft hyphen may display as a hyphen, so it keeps its cell.
+	delete(zero, 0x00ad)
+	// Medial vowels and final consonants join the preceding Hangul initial.
+	for _, rng := range [][2]rune{{0x1160, 0x11ff}, {0xd7b0, 0xd7ff}} {
+		for r := rng[0]; r <= rng[1]; r++ {
+			zero[r] = true
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by gen.go from Unicode %s; DO NOT EDIT.\n\n", version)
+	fmt.Fprintf(&out, "package width\n\n")
+	fmt.Fprintf(&out, "// unicodeVersion is the version of the Unicode data in the tables.\n")
+	fmt.Fprintf(&out, "const unicodeVersion = %q\n\n", version)
+	writeTable(&out, "wide", "wide holds the characters with East Asian Width W or F.", wide)
+	writeTable(&out, "zero", "zero holds the characters that take no cell of their own.", zero)
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("tables.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ucdURL returns the address of the UCD files for a version of Unicode.
+func ucdURL(version string) string {
+	return "https://www.unicode.org/Public/" + version + "/ucd/"
+}
+
+// The official UCD files name their copyright holder and terms of use in the
+// comments at their top, which the files rebuilt from other sources lack.
+const (
+	ucdCopyright = "Unicode®, Inc."
+	ucdTerms     = "unicode.org/terms_of_use.html"
+)
+
+// readUCD calls fn for each line of the UCD file name.txt, with the range of
+// the line's code points and its fields. It returns the Unicode version from
+// the file's first line (e.g., "# EastAsianWidth-17.0.0.txt"). It rejects a
+// file without the copyright and terms of use of the official files.
+func readUCD(dir, name string, fn func(lo, hi rune, fields []string)) (string, error) {
+	f, err := os.Open(filepath.Join(dir, name+".txt"))
+	if err != nil {
+		return "", fmt.Errorf("%w; get the file from %s", err, ucdURL(unicode.Version))
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() {
+		return "", fmt.Errorf("%s.txt: empty file", name)
+	}
+	version, ok := strings.CutPrefix(sc.Text(), "# "+name+"-")
+	version, found := strings.CutSuffix(version, ".txt")
+	if !ok || !found {
+		return "", fmt.Errorf("%s.txt: no version in first line %q", name, sc.Text())
+	}
+	var copyright, terms bool
+	for sc.Scan() {
+		line, comment, _ := strings.Cut(sc.Text(), "#")
+		copyright = copyright || strings.Contains(comment, ucdCopyright)
+		terms = terms || strings.Contains(comment, ucdTerms)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		los, his, isRange := strings.Cut(fields[0], "..")
+		lo, err := parseRune(los)
+		if err != nil {
+			return "", fmt.Errorf("%s.txt: %w", name, err)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = parseRune(his); err != nil {
+				return "", fmt.Errorf("%s.txt: %w", name, err)
+			}
+		}
+		fn(lo, hi, fields)
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	if !copyright || !terms {
+		return "", fmt.Errorf("%s.txt: no Unicode license header; get the official file from %s", name, ucdURL(version))
+	}
+	return version, nil
+}
+
+func parseRune(s string) (rune, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	return rune(n), err
+}
+
+// writeTable writes the set as a sorted table of ranges.
+func writeTable(w io.Writer, name, doc string, set map[rune]bool) {
+	fmt.Fprintf(w, "// %s\nvar %s = []runeRange{\n", doc, name)
+	for r := rune(0); r <= 0x10ffff; r++ {
+		if !set[r] {
+			continue
+		}
+		lo := r
+		for set[r+1] {
+			r++
+		}
+		fmt.Fprintf(w, "\t{0x%04x, 0x%04x},\n", lo, r)
+	}
+	fmt.Fprintf(w, "}\n\n")
+}
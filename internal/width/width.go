@@ -0,0 +1,171 @@
+// Package width measures how many terminal cells text takes up.
+//
+// Most characters take one cell. East Asian wide and fullwidth characters,
+// which include most emoji, take two. Combining marks, format characters,
+// and control characters take none. The tables come from EastAsianWidth.txt
+// and DerivedGeneralCategory.txt of the Unicode Character Database, which go
+// in the ucd directory as published by unicode.org, for the version of
+// Unicode that package unicode uses. Run "go generate" to rebuild them, which
+// needs no network.
+package width
+
+//go:generate go run gen.go -ucd ucd
+
+import "unicode/utf8"
+
+type runeRange struct {
+	lo, hi rune
+}
+
+const (
+	zwj  = 0x200d // ZERO WIDTH JOINER
+	vs16 = 0xfe0f // VARIATION SELECTOR-16, which asks for emoji presentation
+	esc  = 0x1b
+)
+
+// Rune returns the number of cells that r takes by itself.
+func Rune(r rune) int {
+	switch {
+	case r < 0x20:
+		return 0
+	case r < 0x7f:
+		return 1
+	case in(zero, r):
+		return 0
+	case in(wide, r):
+		return 2
+	}
+	return 1
+}
+
+func in(table []runeRange, r rune) bool {
+	lo, hi := 0, len(table)
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		switch {
+		case r < table[m].lo:
+			hi = m
+		case r > table[m].hi:
+			lo = m + 1
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// A cluster tracks the runes before the current one, since emoji sequences
+// take fewer cells than their parts.
+type cluster struct {
+	base   int
+	joined bool
+}
+
+// width returns the number of cells that r adds to the text before it. A rune
+// after a zero width joiner joins the emoji before it, a skin tone modifier
+// after an emoji changes it, and VARIATION SELECTOR-16 widens the narrow
+// character before it to an emoji.
+func (c *cluster) width(r rune) int {
+	joined := c.joined
+	c.joined = r == zwj
+	switch {
+	case joined:
+		return 0
+	case r == vs16 && c.base == 1:
+		c.base = 2
+		return 1
+	case r >= 0x1f3fb && r <= 0x1f3ff && c.base == 2:
+		return 0
+	}
+	w := Rune(r)
+	if w > 0 {
+		c.base = w
+	}
+	return w
+}
+
+// escapeLen returns the length of the terminal escape sequence at the start
+// of b: a control sequence (CSI), such as a color, or an operating system
+// command (OSC), such as a hyperlink. It returns 0 if b does not start with
+// an escape sequence.
+func escapeLen(b []byte) int {
+	if len(b) < 2 || b[0] != esc {
+		return 0
+	}
+	switch b[1] {
+	case '[':
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']':
+		for i := 2; i < len(b); i++ {
+			switch {
+			case b[i] == 0x07:
+				return i + 1
+			case b[i] == esc && i+1 < len(b) && b[i+1] == '\\':
+				return i + 2
+			}
+		}
+	default:
+		return 2
+	}
+	return len(b)
+}
+
+// Bytes returns the number of cells that the UTF-8 text b takes up. Escape
+// sequences take none, and each invalid byte takes one.
+func Bytes(b []byte) int {
+	var c cluster
+	n := 0
+	for i := 0; i < len(b); {
+		if k := escapeLen(b[i:]); k > 0 {
+			i += k
+			continue
+		}
+		r, size := utf8.DecodeRune(b[i:])
+		n += c.width(r)
+		i += size
+	}
+	return n
+}
+
+// Truncate shortens b to at most n cells, ending it with tail if it cuts
+// anything. It never splits a character or an emoji sequence, and it keeps
+// all escape sequences, so that a color or hyperlink is still closed. It
+// reuses the memory of b.
+func Truncate(b []byte, n int, tail string) []byte {
+	if Bytes(b) <= n {
+		return b
+	}
+	room := n - Bytes([]byte(tail))
+	var c cluster
+	used := 0
+	cut := 0
+	for cut < len(b) {
+		if k := escapeLen(b[cut:]); k > 0 {
+			cut += k
+			continue
+		}
+		r, size := utf8.DecodeRune(b[cut:])
+		w := c.width(r)
+		if used+w > room {
+			break
+		}
+		used += w
+		cut += size
+	}
+	var escapes []byte
+	for i := cut; i < len(b); {
+		if k := escapeLen(b[i:]); k > 0 {
+			escapes = append(escapes, b[i:i+k]...)
+			i += k
+			continue
+		}
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+	}
+	b = append(b[:cut], tail...)
+	return append(b, escapes...)
+}
@@ -0,0 +1,71 @@
+package width_test
+
+import (
+	"testing"
+
+	"github.com/telemachus/humane/internal/width"
+)
+
+func TestBytes(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		s    string
+		want int
+	}{
+		"ascii":           {"hello", 5},
+		"empty":           {"", 0},
+		"cjk":             {"日本語", 6},
+		"fullwidth":       {"ＡＢ", 4},
+		"hangul":          {"한국", 4},
+		"hangul jamo":     {"\u1100\u1161\u11a8", 2},
+		"combining":       {"e\u0301", 1},
+		"emoji":           {"🚀", 2},
+		"emoji zwj":       {"👨‍👩‍👧", 2},
+		"skin tone":       {"👍🏽", 2},
+		"emoji vs16":      {"❤️", 2},
+		"text vs15":       {"❤︎", 1},
+		"flag":            {"🇯🇵", 2},
+		"ambiguous":       {"±", 1},
+		"soft hyphen":     {"a\u00adb", 3},
+		"control":         {"a\tb", 2},
+		"invalid":         {"a\xffb", 3},
+		"color":           {"\x1b[31mred\x1b[0m", 3},
+		"hyperlink":       {"\x1b]8;;file:///x\x1b\\x.go\x1b]8;;\x1b\\", 4},
+		"hyperlink (bel)": {"\x1b]8;;file:///x\ax.go\x1b]8;;\a", 4},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := width.Bytes([]byte(tc.s)); got != tc.want {
+				t.Errorf("width.Bytes(%q) = %d; want %d", tc.s, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		s    string
+		n    int
+		want string
+	}{
+		"fits":            {"hello", 5, "hello"},
+		"ascii":           {"hello, world", 6, "hello…"},
+		"cjk":             {"日本語のテキスト", 6, "日本…"},
+		"cjk odd":         {"日本語のテキスト", 7, "日本語…"},
+		"emoji sequence":  {"ab👨‍👩‍👧cd", 5, "ab👨‍👩‍👧…"},
+		"keeps combining": {"e\u0301e\u0301e\u0301", 2, "e\u0301…"},
+		"zero":            {"hello", 0, "…"},
+		"keeps escapes":   {"\x1b]8;;file:///x\x1b\\long.go:10\x1b]8;;\x1b\\", 5, "\x1b]8;;file:///x\x1b\\long…\x1b]8;;\x1b\\"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got := string(width.Truncate([]byte(tc.s), tc.n, "…"))
+			if got != tc.want {
+				t.Errorf("width.Truncate(%q, %d) = %q; want %q", tc.s, tc.n, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package humane
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/telemachus/humane/internal/buffer"
+	"github.com/telemachus/humane/internal/width"
+)
+
+// A Layout is a template for a log line. It is literal text with fields in
+// braces, as in the following example.
+//
+//	{time:15:04:05} {level} {source} | {msg} | {attrs}
+//
+// The fields are the following.
+//
+//   - {time} is the time of the record, displayed according to TimeMode and
+//     TimeFormat. {time:LAYOUT} overrides TimeFormat with LAYOUT (see
+//     [time.Time.Format]). The time is padded to the widest that its format
+//     can produce, so that the fields after it line up. (For TimeSinceStart
+//     and TimeSinceLast, that is a time under three hours or a delta under
+//     a minute.)
+//   - {level} is the level, padded to line up with the other levels.
+//   - {source} is the source of the record, displayed according to
+//     SourceMode. It is empty unless AddSource is true.
+//   - {msg} is the message.
+//   - {attrs} is the Attrs of the record, including those from WithAttrs.
+//     If the layout has no {time} or {source} field, the time and source
+//     appear as the last Attrs.
+//
+// The {level}, {source}, and {msg} fields take an optional width, measured in
+// terminal cells rather than bytes, so that wide characters such as CJK and
+// emoji line up. {msg:30} pads the message with spaces to at least 30 cells,
+// {msg:.50} cuts it to at most 50 cells, ending with "…", and {msg:30.50}
+// does both. A field with a minimum width always counts as present (see
+// below), so that the fields after it stay in line, but the last field of a
+// layout is never padded.
+//
+// Each field may appear at most once. Write "{{" and "}}" for literal braces.
+//
+// A field can be empty, for example when a record has no Attrs. An empty field
+// takes the literal text before it with it, back to the previous field. (E.g.,
+// "{level} | {source} | {msg}" without a source becomes "{level} | {msg}".)
+// If the empty field is the first in the layout, the text after it goes
+// instead, and if it is the last, only the spaces before it go. The {level}
+// and {msg} fields always count as present, even if the message is empty.
+//
+// A Layout implements [encoding.TextUnmarshaler], which checks the layout.
+type Layout string
+
+// MarshalText implements [encoding.TextMarshaler].
+func (l Layout) MarshalText() ([]byte, error) {
+	return []byte(l), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler]. It returns an error if
+// the layout is invalid.
+func (l *Layout) UnmarshalText(data []byte) error {
+	if _, err := parseLayout(string(data)); err != nil {
+		return err
+	}
+	*l = Layout(data)
+	return nil
+}
+
+// The default layouts, which TimePlacement and SourceColumn choose among.
+const (
+	defaultLayout      = "{level} | {msg} | {attrs}"
+	sourceColumnLayout = "{level} | {source} | {msg} | {attrs}"
+	leadingTimePrefix  = "{time} "
+)
+
+type layoutField int
+
+const (
+	fieldText layoutField = iota
+	fieldTime
+	fieldLevel
+	fieldSource
+	fieldMsg
+	fieldAttrs
+)
+
+var layoutFields = map[string]layoutField{
+	"time":   fieldTime,
+	"level":  fieldLevel,
+	"source": fieldSource,
+	"msg":    fieldMsg,
+	"attrs":  fieldAttrs,
+}
+
+// A layoutPart is either literal text or a field. For a field, arg holds the
+// argument after the colon, if any, and min and max hold its width in cells.
+type layoutPart struct {
+	arg   string
+	field layoutField
+	min   int
+	max   int
+}
+
+// layoutFor returns the layout that opts ask for.
+func layoutFor(opts *Options) string {
+	if opts.Layout != "" {
+		return string(opts.Layout)
+	}
+	l := defaultLayout
+	if opts.SourceColumn {
+		l = sourceColumnLayout
+	}
+	if opts.TimePlacement == TimeLeading {
+		l = leadingTimePrefix + l
+	}
+	return l
+}
+
+// parseLayout compiles a layout into its parts.
+func parseLayout(s string) ([]layoutPart, error) {
+	var parts []layoutPart
+	var text strings.Builder
+	seen := map[layoutField]bool{}
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "{{"), strings.HasPrefix(s[i:], "}}"):
+			text.WriteByte(s[i])
+			i += 2
+		case s[i] == '}':
+			return nil, fmt.Errorf("humane: layout %q: unexpected '}' at offset %d", s, i)
+		case s[i] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("humane: layout %q: unclosed '{' at offset %d", s, i)
+			}
+			p, err := parseField(s[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("humane: layout %q: %w", s, err)
+			}
+			if seen[p.field] {
+				return nil, fmt.Errorf("humane: layout %q: field {%s} appears twice", s, s[i+1:i+end])
+			}
+			seen[p.field] = true
+			if text.Len() > 0 {
+				parts = append(parts, layoutPart{arg: text.String()})
+				text.Reset()
+			}
+			parts = append(parts, p)
+			i += end + 1
+		default:
+			text.WriteByte(s[i])
+			i++
+		}
+	}
+	if text.Len() > 0 {
+		parts = append(parts, layoutPart{arg: text.String()})
+	}
+	return parts, nil
+}
+
+func parseField(spec string) (layoutPart, error) {
+	name, arg, hasArg := strings.Cut(spec, ":")
+	f, ok := layoutFields[name]
+	if !ok {
+		return layoutPart{}, fmt.Errorf("unknown field {%s}", spec)
+	}
+	switch {
+	case !hasArg:
+		return layoutPart{field: f}, nil
+	case arg == "", f == fieldAttrs:
+		return layoutPart{}, fmt.Errorf("field {%s} takes no argument", name)
+	case f == fieldTime:
+		return layoutPart{field: f, arg: arg}, nil
+	}
+	p := layoutPart{field: f}
+	if !parseWidth(arg, &p) {
+		return layoutPart{}, fmt.Errorf("field {%s}: invalid width %q", name, arg)
+	}
+	return p, nil
+}
+
+// parseWidth parses a width of the form "MIN", ".MAX", or "MIN.MAX" into p.
+func parseWidth(arg string, p *layoutPart) bool {
+	minArg, maxArg, hasMax := strings.Cut(arg, ".")
+	var err error
+	if minArg != "" {
+		if p.min, err = strconv.Atoi(minArg); err != nil || p.min < 0 {
+			return false
+		}
+	}
+	if hasMax {
+		if p.max, err = strconv.Atoi(maxArg); err != nil || p.max <= 0 {
+			return false
+		}
+	}
+	return p.max == 0 || p.min <= p.max
+}
+
+// fitField pads or truncates a field that has been written to buf starting at
+// start to the field's width. It does not pad the last field of the layout,
+// since trailing spaces would only pad the line.
+func (h *handler) fitField(buf *buffer.Buffer, start int, p layoutPart, last bool) {
+	if p.min == 0 && p.max == 0 {
+		return
+	}
+	w := width.Bytes((*buf)[start:])
+	if p.max > 0 && w > p.max {
+		*buf = append((*buf)[:start], width.Truncate((*buf)[start:], p.max, elided)...)
+		return
+	}
+	for ; w < p.min && !last; w++ {
+		buf.WriteByte(' ')
+	}
+}
+
+// hasField reports whether the layout has the field f.
+func hasField(parts []layoutPart, f layoutField) bool {
+	for _, p := range parts {
+		if p.field == f {
+			return true
+		}
+	}
+	return false
+}
+
+// appendLayout writes a record according to the handler's layout.
+// timeAttr is empty if the record has no time. The {attrs} field is empty if
+// the handler expands the record.
+func (h *handler) appendLayout(buf *buffer.Buffer, r *slog.Record, timeAttr slog.Attr, hl *highlight) {
+	textStart := 0
+	skipText := false
+	for i, p := range h.layout {
+		start := len(*buf)
+		if p.field == fieldText {
+			textStart = start
+			if !skipText {
+				buf.WriteString(p.arg)
+			}
+			skipText = false
+			continue
+		}
+		if i == 0 || h.layout[i-1].field != fieldText {
+			textStart = start
+		}
+		switch p.field {
+		case fieldTime:
+			if !timeAttr.Equal(slog.Attr{}) {
+				h.appendTimeField(buf, timeAttr.Value, p.arg)
+			}
+		case fieldLevel:
+			h.appendLevel(buf, r.Level)
+		case fieldSource:
+			if h.addSource && r.PC != 0 {
+				h.appendSourceColumn(buf, r.PC)
+			}
+		case fieldMsg:
+			h.appendMsg(buf, r.Message, hl)
+		default:
+			if !h.expands(r.Level) {
+				h.appendAttrs(buf, r, timeAttr, hl)
+			}
+		}
+		// A {time} field has a width so that a leading time stays in
+		// line, but a record without a time loses the field as usual.
+		if p.field != fieldTime || len(*buf) > start {
+			h.fitField(buf, start, p, i == len(h.layout)-1)
+		}
+		if len(*buf) > start || p.field == fieldLevel || p.field == fieldMsg {
+			continue
+		}
+		switch {
+		case i == 0:
+			skipText = true
+		case i == len(h.layout)-1:
+			for len(*buf) > textStart && (*buf)[len(*buf)-1] == ' ' {
+				*buf = (*buf)[:len(*buf)-1]
+			}
+		default:
+			*buf = (*buf)[:textStart]
+		}
+	}
+}
+
+// appendAttrs writes the {attrs} field: the handler's preformatted Attrs, the
+// record's Attrs, and the source and time if the layout has no field for them.
+// timeAttr is empty if the record has no time.
+func (h *handler) appendAttrs(buf *buffer.Buffer, r *slog.Record, timeAttr slog.Attr, hl *highlight) {
+	base := len(*buf)
+	if h.attrs != "" {
+		buf.WriteString(h.attrs)
+	}
+	st := h.newState(buf)
+	s := &st
+	s.hl = *hl
+	if h.trackFields() {
+		h.startFields(s, base)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(s, a)
+		return !h.lineFull(buf)
+	})
+	h.dedupe(s)
+	h.orderFields(s)
+	s.track = false
+	h.appendDropped(s)
+	s.count = false
+	if h.addSource && !h.sourceField && r.PC != 0 && !h.lineFull(buf) {
+		h.appendSource(s, r.PC)
+	}
+	if !timeAttr.Equal(slog.Attr{}) && !h.timeField && !h.lineFull(buf) {
+		h.appendStateKey(s, nil, timeAttr.Key)
+		h.appendTimeVal(buf, timeAttr)
+	}
+	h.closeGroups(s, 0)
+	*hl = s.hl
+	// Every Attr starts with a space, but the layout places the first one.
+	if len(*buf) > base && (*buf)[base] == ' ' {
+		*buf = append((*buf)[:base], (*buf)[base+1:]...)
+	}
+}
@@ -0,0 +1,189 @@
+package humane_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/telemachus/humane"
+)
+
+func TestLayout(t *testing.T) {
+	t.Parallel()
+	when := time.Date(2023, 4, 2, 10, 50, 9, 0, time.UTC)
+	tests := map[string]struct {
+		layout humane.Layout
+		attrs  []slog.Attr
+		want   string
+	}{
+		"time first": {
+			layout: "{time:15:04:05} {level} | {msg} | {attrs}",
+			attrs:  []slog.Attr{slog.Int("a", 1)},
+			want:   "10:50:09  INFO | foo | a=1\n",
+		},
+		"no separators": {
+			layout: "{level} {msg} {attrs}",
+			attrs:  []slog.Attr{slog.Int("a", 1), slog.Int("b", 2)},
+			want:   " INFO foo a=1 b=2 time=\"2023-04-02T10:50.09 UTC\"\n",
+		},
+		"time in attrs": {
+			layout: "{msg}: {attrs}",
+			attrs:  []slog.Attr{slog.Int("a", 1)},
+			want:   `foo: a=1 time="2023-04-02T10:50.09 UTC"` + "\n",
+		},
+		"empty last field": {
+			layout: "{time:15:04} [{level}] {msg} | {attrs}",
+			want:   "10:50 [ INFO] foo |\n",
+		},
+		"empty middle field": {
+			layout: "{level} | {source} | {msg}",
+			want:   " INFO | foo\n",
+		},
+		"no attrs field": {
+			layout: "{level} {msg}",
+			attrs:  []slog.Attr{slog.Int("a", 1)},
+			want:   " INFO foo\n",
+		},
+		"braces": {
+			layout: "{{{level}}} {msg}",
+			want:   "{ INFO} foo\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			h := humane.NewHandler(&buf, &humane.Options{Layout: tc.layout})
+			r := slog.NewRecord(when, slog.LevelInfo, "foo", 0)
+			r.AddAttrs(tc.attrs...)
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLayoutEmptyFirstField(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		Layout:      "{time} {level} | {msg} | {attrs}",
+		ReplaceAttr: removeTime,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.With("a", 1).Info("foo", "b", 2)
+	got := buf.String()
+	want := " INFO | foo | a=1 b=2\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestLayoutSource(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		Layout:      "{level} {source} | {msg} | {attrs}",
+		ReplaceAttr: removeTime,
+		AddSource:   true,
+		SourceMode:  humane.SourceBase,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Warn("foo", "a", 1)
+	_, _, line, _ := runtime.Caller(0)
+	got := buf.String()
+	want := fmt.Sprintf(" WARN layout_test.go:%d | foo | a=1\n", line-1)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestLayoutWidth(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		layout humane.Layout
+		msg    string
+		want   string
+	}{
+		"pad ascii": {
+			layout: "{msg:8}| {attrs}",
+			msg:    "foo",
+			want:   "foo     | a=1\n",
+		},
+		"pad cjk": {
+			layout: "{msg:8}| {attrs}",
+			msg:    "日本",
+			want:   "日本    | a=1\n",
+		},
+		"pad emoji": {
+			layout: "{msg:8}| {attrs}",
+			msg:    "🚀 go",
+			want:   "🚀 go   | a=1\n",
+		},
+		"truncate cjk": {
+			layout: "{msg:.5} | {attrs}",
+			msg:    "日本語のテキスト",
+			want:   "日本… | a=1\n",
+		},
+		"pad and truncate": {
+			layout: "{msg:4.6}| {attrs}",
+			msg:    "hello, world",
+			want:   "hello…| a=1\n",
+		},
+		"last field not padded": {
+			layout: "{level} {msg:20}",
+			msg:    "foo",
+			want:   " INFO foo\n",
+		},
+		"padded source": {
+			layout: "{level} {source:6}| {msg}",
+			msg:    "foo",
+			want:   " INFO       | foo\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{Layout: tc.layout, ReplaceAttr: removeTime}
+			slog.New(humane.NewHandler(&buf, opts)).Info(tc.msg, "a", 1)
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLayoutInvalid(t *testing.T) {
+	t.Parallel()
+	for _, layout := range []string{
+		"{level",
+		"level}",
+		"{lvl} {msg}",
+		"{msg} {msg}",
+		"{level} {attrs:5}",
+		"{level} {msg:x}",
+		"{level} {msg:-3}",
+		"{level} {msg:.0}",
+		"{level} {msg:9.3}",
+		"{time:} {msg}",
+	} {
+		var l humane.Layout
+		if err := l.UnmarshalText([]byte(layout)); err == nil {
+			t.Errorf("UnmarshalText(%q): got nil error", layout)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("NewHandler with an invalid layout did not panic")
+		}
+	}()
+	humane.NewHandler(&bytes.Buffer{}, &humane.Options{Layout: "{nope}"})
+}
@@ -0,0 +1,42 @@
+package humane
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// LevelTrace is a level below [log/slog.LevelDebug] for very detailed output.
+// The handler displays it as "TRACE".
+const LevelTrace = slog.LevelDebug - 4
+
+// ParseLevel parses the name of a level: "trace", "debug", "info", "warn", or
+// "error". The name ignores case, and it may have an offset (e.g., "info+2"
+// or "debug-1"), as in [log/slog.Level.UnmarshalText].
+func ParseLevel(s string) (slog.Level, error) {
+	name := strings.TrimSpace(s)
+	trace := len(name) >= len("trace") && strings.EqualFold(name[:len("trace")], "trace")
+	if trace {
+		name = "debug" + name[len("trace"):]
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("humane: unknown level %q (want trace, debug, info, warn, or error, with an optional offset such as +2)", s)
+	}
+	if trace {
+		l += LevelTrace - slog.LevelDebug
+	}
+	return l, nil
+}
+
+// levelName is like [log/slog.Level.String], but it knows LevelTrace.
+func levelName(l slog.Level) string {
+	if l >= LevelTrace && l < slog.LevelDebug {
+		if l == LevelTrace {
+			return "TRACE"
+		}
+		return "TRACE+" + strconv.Itoa(int(l-LevelTrace))
+	}
+	return l.String()
+}
@@ -0,0 +1,207 @@
+package humane
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// runeStart returns the largest index no greater than n that begins a rune in
+// s. Cutting s at that index never splits a multi-byte character.
+func runeStart[S ~string | ~[]byte](s S, n int) int {
+	if n >= len(s) {
+		return len(s)
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// escapeStart moves a cut at n in b back to the start of an escape sequence
+// that the cut would split: a CSI sequence, such as a color, or an OSC
+// sequence, such as a hyperlink. A split sequence would swallow the text after
+// the cut.
+func escapeStart(b []byte, n int) int {
+	for {
+		i := bytes.LastIndexByte(b[:n], 0x1b)
+		if i < 0 || !escapeOpen(b[i:n]) {
+			return n
+		}
+		n = i
+	}
+}
+
+// escapeOpen reports whether seq, which starts with ESC, is an escape sequence
+// that has not ended. An OSC sequence ends with BEL or with ESC \, whose ESC
+// escapeStart finds first.
+func escapeOpen(seq []byte) bool {
+	if len(seq) < 2 {
+		return true
+	}
+	switch seq[1] {
+	case '[':
+		for _, c := range seq[2:] {
+			if c >= 0x40 && c <= 0x7e {
+				return false
+			}
+		}
+		return true
+	case ']':
+		return bytes.IndexByte(seq[2:], 0x07) < 0
+	}
+	return false
+}
+
+// linkOpen reports whether b ends in the text of an OSC 8 hyperlink, that is,
+// whether the last OSC 8 sequence in b starts a link rather than ends one.
+func linkOpen(b []byte) bool {
+	i := bytes.LastIndex(b, []byte("\x1b]8;"))
+	if i < 0 {
+		return false
+	}
+	params := b[i+len("\x1b]8;"):]
+	j := bytes.IndexByte(params, ';')
+	return j >= 0 && j+1 < len(params) && params[j+1] != 0x1b && params[j+1] != 0x07
+}
+
+// appendTruncated writes the marker for a value that lost n bytes.
+func appendTruncated(buf *buffer.Buffer, n int) {
+	buf.WriteString(elided + "(+")
+	*buf = strconv.AppendInt(*buf, int64(n), 10)
+	buf.WriteString(" bytes)")
+}
+
+// valueLimit returns the limit on the length of a value. On a line with a
+// MaxLineLen, no value may be longer than MaxLineLen, since the handler cuts
+// the line there anyway. Zero means no limit.
+func (h *handler) valueLimit() int {
+	if h.maxLineLen <= 0 || (h.maxValueLen > 0 && h.maxValueLen <= h.maxLineLen) {
+		return h.maxValueLen
+	}
+	return h.maxLineLen
+}
+
+// limitWriter copies into buf until buf reaches the length max, and it counts
+// the bytes that it drops. A value written through a limitWriter never grows
+// buf by more than its limit, however long the value is.
+type limitWriter struct {
+	buf     *buffer.Buffer
+	max     int
+	dropped int
+}
+
+// newLimitWriter returns a limitWriter for a value that starts at the end of
+// buf. A limit of zero or less means no limit.
+func newLimitWriter(buf *buffer.Buffer, limit int) limitWriter {
+	w := limitWriter{buf: buf, max: math.MaxInt}
+	if limit > 0 {
+		w.max = len(*buf) + limit
+	}
+	return w
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	writeLimited(w, p)
+	return len(p), nil
+}
+
+func (w *limitWriter) WriteString(s string) (int, error) {
+	writeLimited(w, s)
+	return len(s), nil
+}
+
+func (w *limitWriter) WriteByte(c byte) error {
+	if len(*w.buf) < w.max {
+		*w.buf = append(*w.buf, c)
+	} else {
+		w.dropped++
+	}
+	return nil
+}
+
+func writeLimited[S ~string | ~[]byte](w *limitWriter, p S) {
+	room := max(w.max-len(*w.buf), 0)
+	if len(p) > room {
+		w.dropped += len(p) - room
+		p = p[:room]
+	}
+	*w.buf = append(*w.buf, p...)
+}
+
+// cut marks the value that w has written to buf starting at start if w
+// dropped any of it. It never splits a multi-byte character. It reports
+// whether the value was cut short.
+func (w *limitWriter) cut(start int) bool {
+	if w.dropped == 0 {
+		return false
+	}
+	n := runeStart((*w.buf)[start:], w.max-start)
+	dropped := w.dropped + len(*w.buf) - start - n
+	*w.buf = (*w.buf)[:start+n]
+	appendTruncated(w.buf, dropped)
+	return true
+}
+
+// appendSprint writes v as formatted by fmt.Sprint. If there is a limit on
+// the length of the value, appendSprint never copies more than that limit
+// into buf.
+func (h *handler) appendSprint(buf *buffer.Buffer, v any, limit int) {
+	if s, ok := methodString(v); ok {
+		h.appendString(buf, s, limit)
+		return
+	}
+	start := len(*buf)
+	defer h.recoverValue(buf, start, "print", limit)
+	if limit <= 0 {
+		h.quoteString(buf, fmt.Sprint(v))
+		return
+	}
+	w := newLimitWriter(buf, limit)
+	fmt.Fprint(&w, v)
+	w.cut(start)
+	h.quoteFrom(buf, start)
+}
+
+// lineFull reports whether a line has grown past MaxLineLen.
+func (h *handler) lineFull(buf *buffer.Buffer) bool {
+	return h.maxLineLen > 0 && len(*buf) > h.maxLineLen
+}
+
+// truncateLine enforces MaxLineLen on the line that starts at start in buf and
+// runs to the end of buf.
+func (h *handler) truncateLine(buf *buffer.Buffer, start int) {
+	line := (*buf)[start:]
+	if h.maxLineLen <= 0 || len(line) <= h.maxLineLen {
+		return
+	}
+	*buf = (*buf)[:start+escapeStart(line, runeStart(line, h.maxLineLen))]
+	if h.links && linkOpen((*buf)[start:]) {
+		appendLinkEnd(buf)
+	}
+	if h.color {
+		buf.WriteString(colorReset)
+	}
+	buf.WriteString(elided + "(truncated)")
+}
+
+// appendDropped writes a marker for any Attrs left out because of MaxAttrs.
+func (h *handler) appendDropped(s *state) {
+	if s.dropped == 0 {
+		return
+	}
+	if s.expand {
+		s.line = len(*s.buf) + 1
+		h.appendBlockLine(s.buf, 0)
+	} else {
+		s.buf.WriteByte(' ')
+	}
+	s.buf.WriteString(elided + "(+")
+	*s.buf = strconv.AppendInt(*s.buf, int64(s.dropped), 10)
+	s.buf.WriteString(" attrs)")
+	h.endBlockLine(s)
+}
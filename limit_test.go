@@ -0,0 +1,167 @@
+package humane_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+type bigValue struct{}
+
+func (bigValue) String() string {
+	return strings.Repeat("x", 100)
+}
+
+type bigText struct{}
+
+func (bigText) MarshalText() ([]byte, error) {
+	return bytes.Repeat([]byte("y"), 100), nil
+}
+
+func TestMaxValueLen(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name string
+		want string
+		arg  any
+	}{
+		{
+			name: "short string",
+			arg:  "abc",
+			want: ` INFO | message | v=abc` + "\n",
+		},
+		{
+			name: "long string",
+			arg:  strings.Repeat("a", 1234+10),
+			want: ` INFO | message | v="aaaaaaaaaa…(+1234 bytes)"` + "\n",
+		},
+		{
+			name: "multi-byte characters",
+			arg:  strings.Repeat("é", 10),
+			want: ` INFO | message | v="ééééé…(+10 bytes)"` + "\n",
+		},
+		{
+			name: "stringer",
+			arg:  bigValue{},
+			want: ` INFO | message | v="xxxxxxxxxx…(+90 bytes)"` + "\n",
+		},
+		{
+			name: "text marshaler",
+			arg:  bigText{},
+			want: ` INFO | message | v="yyyyyyyyyy…(+90 bytes)"` + "\n",
+		},
+		{
+			name: "number",
+			arg:  12345678901234,
+			want: ` INFO | message | v=12345678901234` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{ReplaceAttr: removeTime, MaxValueLen: 10}
+			logger := slog.New(humane.NewHandler(&buf, opts))
+			logger.Info("message", "v", tc.arg)
+			got := buf.String()
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMaxValueLenJSON(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr: removeTime,
+		AnyMode:     humane.AnyJSON,
+		MaxValueLen: 8,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "v", []int{1, 2, 3, 4, 5})
+	got := buf.String()
+	want := ` INFO | message | v="[1,2,3,4…(+3 bytes)"` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestMaxValueLenFormatter(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr: removeTime,
+		MaxValueLen: 8,
+		Formatters: &humane.Formatters{
+			Kinds: map[slog.Kind]humane.Formatter{slog.KindInt64: humane.FormatNumber},
+		},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "n", 1234, "big", 1234567890123)
+	got := buf.String()
+	want := ` INFO | message | n=1,234 big="1,234,56…(+9 bytes)"` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestMaxLineLen(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime, MaxLineLen: 30}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "a", "aaaa", "b", "bbbbbbbbbb", "c", "c")
+	logger.Info("short")
+	got := buf.String()
+	want := ` INFO | message | a=aaaa b=bbb…(truncated)` + "\n" +
+		` INFO | short |` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestMaxLineLenLongValue(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime, MaxLineLen: 30}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("m", "v", strings.Repeat("a", 1<<20), "w", 1)
+	got := buf.String()
+	want := ` INFO | m | v="` + strings.Repeat("a", 15) + `…(truncated)` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestMaxAttrs(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime, MaxAttrs: 3}
+	logger := slog.New(humane.NewHandler(&buf, opts)).With("a", 1)
+	logger.Info("message", "b", 2, slog.Group("g", "c", 3, "d", 4), "e", 5)
+	logger.Info("message", "b", 2)
+	got := buf.String()
+	want := ` INFO | message | a=1 b=2 g.c=3 …(+2 attrs)` + "\n" +
+		` INFO | message | a=1 b=2` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestMaxAttrsWithAttrs(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime, MaxAttrs: 1}
+	logger := slog.New(humane.NewHandler(&buf, opts)).With("a", 1, "b", 2)
+	logger.Info("message", "c", 3)
+	got := buf.String()
+	want := ` INFO | message | a=1 …(+2 attrs)` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package humane
+
+import (
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// defaultSourceLinkFormat links to the file itself.
+const defaultSourceLinkFormat = "file://{path}"
+
+// sourceLink returns the URL for a source link. It fills in the {path} and
+// {line} placeholders in the handler's SourceLinkFormat.
+func (h *handler) sourceLink(f runtime.Frame) string {
+	path := filepath.ToSlash(f.File)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths such as C:/src/main.go need a leading slash to
+		// form a valid URL.
+		path = "/" + path
+	}
+	r := strings.NewReplacer(
+		"{path}", (&url.URL{Path: path}).EscapedPath(),
+		"{line}", strconv.Itoa(f.Line),
+	)
+	return r.Replace(h.linkFormat)
+}
+
+// appendLinkStart starts an OSC 8 hyperlink to link. Terminals that support
+// OSC 8 display the text between appendLinkStart and appendLinkEnd as a link
+// to the URL, and terminals that do not should ignore the escape sequences.
+// (See https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda.)
+func appendLinkStart(buf *buffer.Buffer, link string) {
+	buf.WriteString("\x1b]8;;")
+	buf.WriteString(link)
+	buf.WriteString("\x1b\\")
+}
+
+// appendLinkEnd ends an OSC 8 hyperlink.
+func appendLinkEnd(buf *buffer.Buffer) {
+	buf.WriteString("\x1b]8;;\x1b\\")
+}
@@ -0,0 +1,124 @@
+package humane_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func TestSourceLinks(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr: removeTime,
+		AddSource:   true,
+		SourceLinks: true,
+		Terminal:    humane.Always,
+		Color:       humane.Never,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo")
+	_, file, line, _ := runtime.Caller(0)
+	got := buf.String()
+	want := fmt.Sprintf(
+		" INFO | foo | source=\x1b]8;;file://%s\x1b\\link_test.go:%d\x1b]8;;\x1b\\\n",
+		file,
+		line-1,
+	)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSourceLinksFormat(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr:      removeTime,
+		AddSource:        true,
+		SourceMode:       humane.SourceFunc,
+		SourceColumn:     true,
+		SourceLinks:      true,
+		SourceLinkFormat: "vscode://file{path}:{line}",
+		Terminal:         humane.Always,
+		Color:            humane.Never,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo")
+	_, file, line, _ := runtime.Caller(0)
+	got := buf.String()
+	want := fmt.Sprintf(
+		" INFO | \x1b]8;;vscode://file%s:%d\x1b\\humane_test.TestSourceLinksFormat\x1b]8;;\x1b\\ | foo |\n",
+		file,
+		line-1,
+	)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSourceLinksNotTerminal(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr: removeTimeTrimSource,
+		AddSource:   true,
+		SourceLinks: true,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo")
+	_, _, line, _ := runtime.Caller(0)
+	got := buf.String()
+	want := fmt.Sprintf(" INFO | foo | source=link_test.go:%d\n", line-1)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSourceLinksMaxLineLen(t *testing.T) {
+	t.Parallel()
+	_, file, _, _ := runtime.Caller(0)
+	prefix := " INFO | "
+	linkStart := "\x1b]8;;file://" + file + "\x1b\\"
+	testCases := []struct {
+		name       string
+		want       string
+		maxLineLen int
+	}{
+		{
+			name:       "cut in the link's URL",
+			maxLineLen: 30,
+			want:       prefix + "…(truncated)\n",
+		},
+		{
+			name:       "cut in the link's text",
+			maxLineLen: len(prefix) + len(linkStart) + 4,
+			want:       prefix + linkStart + "link\x1b]8;;\x1b\\…(truncated)\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{
+				ReplaceAttr:  removeTime,
+				AddSource:    true,
+				SourceMode:   humane.SourceBase,
+				SourceColumn: true,
+				SourceLinks:  true,
+				Terminal:     humane.Always,
+				Color:        humane.Never,
+				MaxLineLen:   tc.maxLineLen,
+			}
+			logger := slog.New(humane.NewHandler(&buf, opts))
+			logger.Info("foo")
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
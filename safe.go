@@ -0,0 +1,117 @@
+package humane
+
+import (
+	"encoding"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// maxLogValues matches the limit in [log/slog.Value.Resolve].
+const maxLogValues = 100
+
+// panicText returns the placeholder that the handler displays instead of a
+// value when a LogValue, MarshalText, String, or Error method or a Formatter
+// panics (e.g., "!PANIC(String: boom)").
+func panicText(op string, r any) string {
+	return "!PANIC(" + op + ": " + fmt.Sprint(r) + ")"
+}
+
+// errorText returns the placeholder that the handler displays instead of a
+// value when MarshalText returns an error (e.g., "!ERROR(marshal: boom)").
+func errorText(op string, err error) string {
+	return "!ERROR(" + op + ": " + err.Error() + ")"
+}
+
+// resolve is like [log/slog.Value.Resolve], but if a LogValue method panics,
+// resolve returns a placeholder rather than an error with a stack trace.
+func resolve(v slog.Value) (rv slog.Value) {
+	defer func() {
+		if r := recover(); r != nil {
+			rv = slog.StringValue(panicText("LogValue", r))
+		}
+	}()
+	for i := 0; i < maxLogValues && v.Kind() == slog.KindLogValuer; i++ {
+		v = v.LogValuer().LogValue()
+	}
+	return v.Resolve()
+}
+
+// appendMarshaled writes the text form of tm or a placeholder. It never
+// copies more than limit bytes of the text.
+func (h *handler) appendMarshaled(buf *buffer.Buffer, tm encoding.TextMarshaler, limit int) {
+	start := len(*buf)
+	defer h.recoverValue(buf, start, "marshal", limit)
+	data, err := tm.MarshalText()
+	if err != nil {
+		h.appendString(buf, errorText("marshal", err), limit)
+		return
+	}
+	if limit <= 0 || len(data) <= limit {
+		h.appendString(buf, string(data), limit)
+		return
+	}
+	w := newLimitWriter(buf, limit)
+	w.Write(data)
+	w.cut(start)
+	h.quoteFrom(buf, start)
+}
+
+// methodString calls the Error or String method of v, as [fmt.Sprint] would,
+// and reports whether v has such a method. If the method panics,
+// methodString returns a placeholder, except that a nil pointer dereference
+// yields "<nil>", as a nil receiver does in package fmt. (Package fmt recovers
+// by itself from a panicking method of a value nested inside v.)
+func methodString(v any) (s string, ok bool) {
+	var op string
+	var method func() string
+	switch m := v.(type) {
+	case fmt.Formatter:
+		return "", false
+	case error:
+		op, method = "Error", m.Error
+	case fmt.Stringer:
+		op, method = "String", m.String
+	default:
+		return "", false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s, ok = panicText(op, r), true
+			if nilDereference(r) {
+				s = "<nil>"
+			}
+		}
+	}()
+	return method(), true
+}
+
+// nilDereference reports whether r, recovered from a panic, is the runtime
+// error of a nil pointer dereference. From a String or Error method, that
+// almost always means a nil receiver, and so methodString can tell a nil
+// receiver without calling the method a second time. Without package reflect,
+// it cannot tell a nil receiver from a method that dereferences some other
+// nil pointer.
+func nilDereference(r any) bool {
+	err, ok := r.(runtime.Error)
+	if !ok {
+		return false
+	}
+	msg := err.Error()
+	// A value method called through a nil pointer fails in the wrapper that
+	// the compiler generates, with a message of its own.
+	return strings.Contains(msg, "nil pointer dereference") || strings.Contains(msg, "called using nil")
+}
+
+// recoverValue is deferred by code that writes a value to buf starting at
+// start. If that code panics, recoverValue replaces whatever it wrote with a
+// placeholder, since logging must never crash a program.
+func (h *handler) recoverValue(buf *buffer.Buffer, start int, op string, limit int) {
+	if r := recover(); r != nil {
+		*buf = (*buf)[:start]
+		h.appendString(buf, panicText(op, r), limit)
+	}
+}
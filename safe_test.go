@@ -0,0 +1,189 @@
+package humane_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+type panicStringer struct{}
+
+func (panicStringer) String() string {
+	panic("boom")
+}
+
+type panicError struct{}
+
+func (panicError) Error() string {
+	panic("boom")
+}
+
+type nilStringer struct {
+	name string
+}
+
+func (s *nilStringer) String() string {
+	return s.name
+}
+
+type valueStringer struct{}
+
+func (valueStringer) String() string {
+	return "value"
+}
+
+// countingStringer counts the calls to its String method, which panics.
+type countingStringer struct {
+	calls int
+}
+
+func (c *countingStringer) String() string {
+	c.calls++
+	panic("boom")
+}
+
+type panicMarshaler struct{}
+
+func (panicMarshaler) MarshalText() ([]byte, error) {
+	panic("boom")
+}
+
+type failMarshaler struct{}
+
+func (failMarshaler) MarshalText() ([]byte, error) {
+	return nil, errors.New("boom")
+}
+
+type panicLogValuer struct{}
+
+func (panicLogValuer) LogValue() slog.Value {
+	panic("boom")
+}
+
+type panicJSON struct{}
+
+func (panicJSON) MarshalJSON() ([]byte, error) {
+	panic("boom")
+}
+
+func TestSafeRendering(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name string
+		want string
+		opts humane.Options
+		arg  any
+	}{
+		{
+			name: "panicking String",
+			arg:  panicStringer{},
+			want: ` INFO | message | v="!PANIC(String: boom)" ok=1` + "\n",
+		},
+		{
+			name: "panicking String with limit",
+			opts: humane.Options{MaxValueLen: 100},
+			arg:  panicStringer{},
+			want: ` INFO | message | v="!PANIC(String: boom)" ok=1` + "\n",
+		},
+		{
+			name: "panicking Error",
+			arg:  panicError{},
+			want: ` INFO | message | v="!PANIC(Error: boom)" ok=1` + "\n",
+		},
+		{
+			name: "nil pointer Stringer",
+			arg:  (*nilStringer)(nil),
+			want: ` INFO | message | v=<nil> ok=1` + "\n",
+		},
+		{
+			name: "nil pointer to a value Stringer",
+			arg:  (*valueStringer)(nil),
+			want: ` INFO | message | v=<nil> ok=1` + "\n",
+		},
+		{
+			name: "nested panicking String",
+			arg:  []any{panicStringer{}},
+			want: ` INFO | message | v="[%!v(PANIC=String method: boom)]" ok=1` + "\n",
+		},
+		{
+			name: "text like fmt's panic text",
+			arg:  "%!v(PANIC=String method: boom)",
+			want: ` INFO | message | v="%!v(PANIC=String method: boom)" ok=1` + "\n",
+		},
+		{
+			name: "panicking MarshalText",
+			arg:  panicMarshaler{},
+			want: ` INFO | message | v="!PANIC(marshal: boom)" ok=1` + "\n",
+		},
+		{
+			name: "failing MarshalText",
+			arg:  failMarshaler{},
+			want: ` INFO | message | v="!ERROR(marshal: boom)" ok=1` + "\n",
+		},
+		{
+			name: "panicking LogValue",
+			arg:  panicLogValuer{},
+			want: ` INFO | message | v="!PANIC(LogValue: boom)" ok=1` + "\n",
+		},
+		{
+			name: "panicking MarshalJSON",
+			opts: humane.Options{AnyMode: humane.AnyJSON},
+			arg:  panicJSON{},
+			want: ` INFO | message | v={} ok=1` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := tc.opts
+			opts.ReplaceAttr = removeTime
+			logger := slog.New(humane.NewHandler(&buf, &opts))
+			logger.Info("message", "v", tc.arg, "ok", 1)
+			got := buf.String()
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSafeFormatter(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr: removeTime,
+		Formatters: &humane.Formatters{
+			Kinds: map[slog.Kind]humane.Formatter{
+				slog.KindInt64: func([]byte, slog.Value) ([]byte, bool) {
+					panic("boom")
+				},
+			},
+		},
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("message", "v", 1, "ok", true)
+	got := buf.String()
+	want := ` INFO | message | v="!PANIC(format: boom)" ok=true` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestPanickingStringCalledOnce(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(humane.NewHandler(&buf, &humane.Options{ReplaceAttr: removeTime}))
+	c := &countingStringer{}
+	logger.Info("message", "v", c)
+	if c.calls != 1 {
+		t.Errorf("String called %d times; want 1", c.calls)
+	}
+	want := ` INFO | message | v="!PANIC(String: boom)"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
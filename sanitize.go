@@ -0,0 +1,81 @@
+package humane
+
+import (
+	"unicode/utf8"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// needsQuoting reports whether the handler must quote s. If the handler
+// sanitizes its output, it quotes any key or value with a control character.
+func (h *handler) needsQuoting(s string) bool {
+	return needsQuoting(s) || (h.sanitize && hasControl(s))
+}
+
+// hasControl reports whether s holds an ASCII control character. (Since
+// needsQuoting already catches C1 controls and invalid UTF-8, hasControl
+// looks only at ASCII.)
+func hasControl(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// appendText writes text that the handler never quotes, such as the message.
+// If the handler sanitizes its output, appendText escapes control characters
+// (e.g., "\x1b" or "\u009b") and invalid bytes (e.g., "\xff") in place.
+// Text from user input may hold such characters, and written raw to a
+// terminal, an escape sequence can recolor or rewrite the screen, while a
+// carriage return or newline can forge a log line.
+func (h *handler) appendText(buf *buffer.Buffer, s string) {
+	if !h.sanitize {
+		buf.WriteString(s)
+		return
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 || c == 0x7f {
+				appendControl(buf, c)
+			} else {
+				buf.WriteByte(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf.WriteString(`\x`)
+			appendHex(buf, c)
+		case r < 0xa0:
+			buf.WriteString(`\u00`)
+			appendHex(buf, byte(r))
+		default:
+			buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+}
+
+func appendControl(buf *buffer.Buffer, c byte) {
+	switch c {
+	case '\t':
+		buf.WriteString(`\t`)
+	case '\n':
+		buf.WriteString(`\n`)
+	case '\r':
+		buf.WriteString(`\r`)
+	default:
+		buf.WriteString(`\x`)
+		appendHex(buf, c)
+	}
+}
+
+func appendHex(buf *buffer.Buffer, c byte) {
+	buf.WriteByte(hexDigits[c>>4])
+	buf.WriteByte(hexDigits[c&0xf])
+}
@@ -0,0 +1,82 @@
+package humane_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func TestSanitize(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{Sanitize: humane.Always, ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("login\r\x1b[2K INFO | fake\n",
+		"user", "bob\x1b]0;pwned\a",
+		"k\x1b", 1,
+		"c1", "a\u009bb",
+		"bad", "a\xffb",
+		"ok", "héllo",
+	)
+	got := buf.String()
+	want := ` INFO | login\r\x1b[2K INFO | fake\n | ` +
+		`user="bob\x1b]0;pwned\a" "k\x1b"=1 c1="a\u009bb" bad="a\xffb" ok=héllo` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSanitizeMessageOnly(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{Sanitize: humane.Always, ReplaceAttr: removeTime}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("tab\there \u0085 \xc3 日本")
+	got := buf.String()
+	want := ` INFO | tab\there \u0085 \xc3 日本 |` + "\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSanitizeOff(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	// A bytes.Buffer is not a terminal, so Auto leaves output alone.
+	logger := slog.New(humane.NewHandler(&buf, &humane.Options{ReplaceAttr: removeTime}))
+	logger.Info("a\x1bb", "k", "v\rw")
+	got := buf.String()
+	want := " INFO | a\x1bb | k=v\rw\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSanitizeComposite(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		mode humane.AnyMode
+		want string
+	}{
+		"json":    {mode: humane.AnyJSON, want: ` INFO | m | v="{\"a\":\"x\\u009b31m\"}"` + "\n"},
+		"flatten": {mode: humane.AnyFlatten, want: ` INFO | m | v.a="x\u009b31m"` + "\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{Sanitize: humane.Always, AnyMode: tc.mode, ReplaceAttr: removeTime}
+			logger := slog.New(humane.NewHandler(&buf, opts))
+			logger.Info("m", "v", map[string]string{"a": "x\u009b31m"})
+			got := buf.String()
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+			if bytes.Contains(buf.Bytes(), []byte("\u009b")) {
+				t.Errorf("output %q holds a raw C1 control character", got)
+			}
+		})
+	}
+}
@@ -16,15 +16,30 @@ import (
 // Thanks to Jonathan Amsterdam for both.
 func TestSlogtest(t *testing.T) {
 	t.Parallel()
+	testSlogtest(t, &humane.Options{TimeFormat: time.RFC3339}, ".")
+}
+
+func TestSlogtestEscapedKeys(t *testing.T) {
+	t.Parallel()
+	opts := &humane.Options{
+		TimeFormat:     time.RFC3339,
+		GroupSeparator: "/",
+		EscapeKeys:     true,
+	}
+	testSlogtest(t, opts, "/")
+}
+
+func testSlogtest(t *testing.T, opts *humane.Options, sep string) {
+	t.Helper()
 	var buf bytes.Buffer
-	h := humane.NewHandler(&buf, &humane.Options{TimeFormat: time.RFC3339})
+	h := humane.NewHandler(&buf, opts)
 	results := func() []map[string]any {
 		ms := []map[string]any{}
 		for _, line := range bytes.Split(buf.Bytes(), []byte{'\n'}) {
 			if len(line) == 0 {
 				continue
 			}
-			m, err := parseHumane(line)
+			m, err := parseHumane(line, sep)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -37,7 +52,7 @@ func TestSlogtest(t *testing.T) {
 	}
 }
 
-func parseHumane(bs []byte) (map[string]any, error) {
+func parseHumane(bs []byte, sep string) (map[string]any, error) {
 	top := map[string]any{}
 	s := string(bytes.TrimSpace(bs))
 	// First, we need to divide each line into three parts (level, message,
@@ -57,7 +72,7 @@ func parseHumane(bs []byte) (map[string]any, error) {
 		if !found {
 			return nil, fmt.Errorf("no '=' in %q", kv)
 		}
-		keys := strings.Split(k, ".")
+		keys := splitKey(k, sep)
 		// Populate a tree of maps for a dotted path such as "a.b.c=x".
 		m := top
 		for _, key := range keys[:len(keys)-1] {
@@ -79,3 +94,31 @@ func parseHumane(bs []byte) (map[string]any, error) {
 	}
 	return top, nil
 }
+
+// splitKey splits a key into the names of its groups and the key itself. A
+// backslash escapes the separator or another backslash.
+func splitKey(k, sep string) []string {
+	var keys []string
+	var b strings.Builder
+	for i := 0; i < len(k); {
+		switch {
+		case k[i] == '\\' && i+1 < len(k):
+			next := k[i+1:]
+			if strings.HasPrefix(next, sep) {
+				b.WriteString(sep)
+				i += 1 + len(sep)
+				continue
+			}
+			b.WriteByte(next[0])
+			i += 2
+		case strings.HasPrefix(k[i:], sep):
+			keys = append(keys, b.String())
+			b.Reset()
+			i += len(sep)
+		default:
+			b.WriteByte(k[i])
+			i++
+		}
+	}
+	return append(keys, b.String())
+}
@@ -0,0 +1,237 @@
+package humane
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/telemachus/humane/internal/buffer"
+)
+
+// A SourceMode determines how the handler displays the source Attr.
+type SourceMode int
+
+const (
+	// SourceFull displays the absolute path and line (e.g.,
+	// "/home/me/proj/internal/db/db.go:42"). This is the default.
+	SourceFull SourceMode = iota
+
+	// SourceBase displays only the file name and line (e.g., "db.go:42").
+	SourceBase
+
+	// SourceRelative displays the path relative to the root of the module
+	// that contains the file and the line (e.g., "internal/db/db.go:42").
+	// The handler finds the root by looking for go.mod in the file's
+	// directory and its parents. If there is no go.mod, it tries GOPATH
+	// instead. If both fail, it displays the absolute path.
+	SourceRelative
+
+	// SourceFunc displays the package-qualified name of the function
+	// (e.g., "db.(*Conn).Query").
+	SourceFunc
+)
+
+var sourceModeNames = []string{"full", "base", "relative", "func"}
+
+// String returns the name of s.
+func (s SourceMode) String() string {
+	return enumString(sourceModeNames, "SourceMode", s)
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (s SourceMode) MarshalText() ([]byte, error) {
+	return marshalEnum(sourceModeNames, "SourceMode", s)
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (s *SourceMode) UnmarshalText(data []byte) error {
+	return unmarshalEnum(sourceModeNames, "SourceMode", data, s)
+}
+
+// A source is the cached text of a source Attr and, if the handler displays
+// links, the URL that the text should link to.
+type source struct {
+	text string
+	link string
+}
+
+// sources caches source Attrs by program counter. A handler and all of its
+// clones share one cache.
+type sources struct {
+	m  map[uintptr]source
+	mu sync.RWMutex
+}
+
+// source returns the source for pc. It formats each call site only once.
+func (h *handler) source(pc uintptr) source {
+	h.sources.mu.RLock()
+	src, ok := h.sources.m[pc]
+	h.sources.mu.RUnlock()
+	if !ok {
+		f := frame(pc)
+		src = source{text: h.sourceText(f)}
+		if h.links {
+			src.link = h.sourceLink(f)
+		}
+		h.sources.mu.Lock()
+		h.sources.m[pc] = src
+		h.sources.mu.Unlock()
+	}
+	return src
+}
+
+// newSourceAttr returns the source Attr for pc.
+func (h *handler) newSourceAttr(pc uintptr) slog.Attr {
+	return slog.String(slog.SourceKey, h.source(pc).text)
+}
+
+func (h *handler) sourceText(f runtime.Frame) string {
+	if h.sourceMode == SourceFunc {
+		return funcName(f.Function)
+	}
+	file := f.File
+	switch h.sourceMode {
+	case SourceBase:
+		file = filepath.Base(file)
+	case SourceRelative:
+		file = relativePath(file)
+	default:
+		// A link carries the full path, so the text can be short.
+		if h.links {
+			file = filepath.Base(file)
+		}
+	}
+	buf := make([]byte, 0, len(file)+8)
+	buf = append(buf, file...)
+	buf = append(buf, ':')
+	buf = strconv.AppendInt(buf, int64(f.Line), 10)
+	return string(buf)
+}
+
+func frame(pc uintptr) runtime.Frame {
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	return f
+}
+
+// funcName strips the import path from a fully-qualified function name
+// (e.g., "github.com/me/proj/db.(*Conn).Query" becomes "db.(*Conn).Query").
+func funcName(fn string) string {
+	if i := strings.LastIndexByte(fn, '/'); i >= 0 {
+		return fn[i+1:]
+	}
+	return fn
+}
+
+// relativePath returns file relative to its module root or to GOPATH.
+func relativePath(file string) string {
+	if !filepath.IsAbs(file) {
+		// Built with -trimpath, so the path already starts with the
+		// module path.
+		return file
+	}
+	if root, ok := moduleRoot(filepath.Dir(file)); ok {
+		if rel, err := filepath.Rel(root, file); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	for _, gopath := range gopaths() {
+		src := filepath.Join(gopath, "src") + string(filepath.Separator)
+		if strings.HasPrefix(file, src) {
+			return filepath.ToSlash(file[len(src):])
+		}
+	}
+	return file
+}
+
+// gopaths returns the directories in GOPATH or the default GOPATH.
+func gopaths() []string {
+	if gopath := os.Getenv("GOPATH"); gopath != "" {
+		return filepath.SplitList(gopath)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, "go")}
+}
+
+// moduleRoots caches the module root for each directory that holds a source
+// file. A directory outside any module maps to the empty string.
+var moduleRoots = struct {
+	dirs map[string]string
+	mu   sync.Mutex
+}{dirs: map[string]string{}}
+
+func moduleRoot(dir string) (string, bool) {
+	moduleRoots.mu.Lock()
+	defer moduleRoots.mu.Unlock()
+	if root, ok := moduleRoots.dirs[dir]; ok {
+		return root, root != ""
+	}
+	root := ""
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			root = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	moduleRoots.dirs[dir] = root
+	return root, root != ""
+}
+
+// appendSource writes the source Attr for pc. The source is a link if the
+// handler displays links.
+func (h *handler) appendSource(s *state, pc uintptr) {
+	if !h.links {
+		h.appendAttr(s, h.newSourceAttr(pc))
+		return
+	}
+	src := h.source(pc)
+	a := slog.String(slog.SourceKey, src.text)
+	if h.replaceAttr != nil {
+		a = h.replaceAttr(s.groups, a)
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	h.appendStateKey(s, s.groups, a.Key)
+	appendLinkStart(s.buf, src.link)
+	h.appendVal(s.buf, a.Key, a.Value, h.valueLimit())
+	appendLinkEnd(s.buf)
+	h.endBlockLine(s)
+}
+
+// appendSourceColumn writes the source as the {source} field of a layout
+// rather than as an Attr. It writes nothing if ReplaceAttr removes the source
+// Attr.
+func (h *handler) appendSourceColumn(buf *buffer.Buffer, pc uintptr) {
+	src := h.source(pc)
+	a := slog.String(slog.SourceKey, src.text)
+	if h.replaceAttr != nil {
+		a = h.replaceAttr(h.groups, a)
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if h.links {
+		appendLinkStart(buf, src.link)
+	}
+	if a.Value.Kind() == slog.KindString {
+		h.appendText(buf, a.Value.String())
+	} else {
+		h.appendVal(buf, a.Key, a.Value, h.maxValueLen)
+	}
+	if h.links {
+		appendLinkEnd(buf)
+	}
+}
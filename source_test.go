@@ -0,0 +1,136 @@
+package humane_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+
+	"github.com/telemachus/humane"
+	"github.com/telemachus/humane/internal/sourcetest"
+)
+
+func TestSourceModes(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name string
+		want string
+		mode humane.SourceMode
+	}{
+		{name: "base", mode: humane.SourceBase, want: " INFO | foo | source=source_test.go:%d\n"},
+		{name: "relative", mode: humane.SourceRelative, want: " INFO | foo | source=source_test.go:%d\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{ReplaceAttr: removeTime, AddSource: true, SourceMode: tc.mode}
+			logger := slog.New(humane.NewHandler(&buf, opts))
+			logger.Info("foo")
+			_, _, line, _ := runtime.Caller(0)
+			got := buf.String()
+			want := fmt.Sprintf(tc.want, line-1)
+			if got != want {
+				t.Errorf("got %q; want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSourceModesSubdirectory(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name string
+		want string
+		mode humane.SourceMode
+	}{
+		{name: "base", mode: humane.SourceBase, want: " INFO | foo | source=sourcetest.go:%d\n"},
+		{
+			name: "relative",
+			mode: humane.SourceRelative,
+			want: " INFO | foo | source=internal/sourcetest/sourcetest.go:%d\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			opts := &humane.Options{ReplaceAttr: removeTime, AddSource: true, SourceMode: tc.mode}
+			line := sourcetest.Info(slog.New(humane.NewHandler(&buf, opts)), "foo")
+			got := buf.String()
+			want := fmt.Sprintf(tc.want, line)
+			if got != want {
+				t.Errorf("got %q; want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSourceFull(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime, AddSource: true}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo")
+	_, file, line, _ := runtime.Caller(0)
+	got := buf.String()
+	want := fmt.Sprintf(" INFO | foo | source=%s:%d\n", file, line-1)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSourceFunc(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime, AddSource: true, SourceMode: humane.SourceFunc}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo")
+	got := buf.String()
+	want := " INFO | foo | source=humane_test.TestSourceFunc\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSourceColumn(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr:  removeTime,
+		AddSource:    true,
+		SourceMode:   humane.SourceBase,
+		SourceColumn: true,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo", "a", 1)
+	_, _, line, _ := runtime.Caller(0)
+	got := buf.String()
+	want := fmt.Sprintf(" INFO | source_test.go:%d | foo | a=1\n", line-1)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSourceColumnRemoved(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == slog.SourceKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+		AddSource:    true,
+		SourceColumn: true,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo")
+	got := buf.String()
+	want := " INFO | foo |\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
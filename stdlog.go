@@ -0,0 +1,185 @@
+package humane
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// LogOptions are options for [RedirectStdLog].
+type LogOptions struct {
+	// Level is the level of each record. The default is
+	// [log/slog.LevelInfo].
+	Level slog.Level
+
+	// InferLevel tells the handler to take the level of a record from the
+	// start of its message if the message starts with a level marker such as
+	// "[WARN]", "ERROR:", or "debug:". The marker is removed from the
+	// message. A message without a marker uses Level.
+	InferLevel bool
+}
+
+// NewLogLogger returns a [log.Logger] that sends each line it writes to h as a
+// record at the given level. If the caller later sets a prefix or flags on
+// the logger, the handler strips them from the message.
+func NewLogLogger(h slog.Handler, level slog.Level) *log.Logger {
+	w := &logWriter{h: h, level: level}
+	w.logger = log.New(w, "", 0)
+	return w.logger
+}
+
+// RedirectStdLog sends the output of the standard logger in package [log] to
+// h, so that lines from code that calls [log.Printf] and friends look like any
+// other record. It clears the standard logger's prefix and flags. If opts is
+// nil, RedirectStdLog uses the zero LogOptions.
+//
+// RedirectStdLog returns a function that restores the standard logger's
+// previous output, prefix, and flags.
+func RedirectStdLog(h slog.Handler, opts *LogOptions) (restore func()) {
+	if opts == nil {
+		opts = &LogOptions{}
+	}
+	std := log.Default()
+	out, prefix, flags := std.Writer(), std.Prefix(), std.Flags()
+	std.SetFlags(0)
+	std.SetPrefix("")
+	std.SetOutput(&logWriter{
+		h:      h,
+		logger: std,
+		level:  opts.Level,
+		infer:  opts.InferLevel,
+	})
+	return func() {
+		std.SetOutput(out)
+		std.SetPrefix(prefix)
+		std.SetFlags(flags)
+	}
+}
+
+// A logWriter turns each write from a [log.Logger] into a record.
+type logWriter struct {
+	h      slog.Handler
+	logger *log.Logger
+	level  slog.Level
+	infer  bool
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	msg = stripLogHeader(msg, w.logger.Prefix(), w.logger.Flags())
+	level := w.level
+	if w.infer {
+		level, msg = inferLevel(msg, level)
+	}
+	ctx := context.Background()
+	if !w.h.Enabled(ctx, level) {
+		return len(p), nil
+	}
+	r := slog.NewRecord(time.Now(), level, msg, logCaller())
+	return len(p), w.h.Handle(ctx, r)
+}
+
+// stripLogHeader removes the prefix and the date, time, and file that a
+// [log.Logger] with the given prefix and flags writes before each message.
+func stripLogHeader(msg, prefix string, flags int) string {
+	if flags&log.Lmsgprefix == 0 {
+		msg = strings.TrimPrefix(msg, prefix)
+	}
+	if flags&log.Ldate != 0 {
+		msg = cutField(msg, len("2009/01/23 "))
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n := len("01:23:23 ")
+		if flags&log.Lmicroseconds != 0 {
+			n = len("01:23:23.123123 ")
+		}
+		msg = cutField(msg, n)
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		if _, after, ok := strings.Cut(msg, ": "); ok {
+			msg = after
+		}
+	}
+	if flags&log.Lmsgprefix != 0 {
+		msg = strings.TrimPrefix(msg, prefix)
+	}
+	return msg
+}
+
+// cutField removes the first n bytes of msg if they end with a space.
+func cutField(msg string, n int) string {
+	if len(msg) < n || msg[n-1] != ' ' {
+		return msg
+	}
+	return msg[n:]
+}
+
+// levelMarkers maps the words that can mark a level, in lower case, to levels.
+var levelMarkers = map[string]slog.Level{
+	"trace":   LevelTrace,
+	"debug":   slog.LevelDebug,
+	"info":    slog.LevelInfo,
+	"notice":  slog.LevelInfo,
+	"warn":    slog.LevelWarn,
+	"warning": slog.LevelWarn,
+	"err":     slog.LevelError,
+	"error":   slog.LevelError,
+	"fatal":   slog.LevelError,
+	"panic":   slog.LevelError,
+}
+
+// maxMarkerLen is the length of the longest word in levelMarkers.
+const maxMarkerLen = len("warning")
+
+// inferLevel looks for a level marker such as "[WARN]" or "error:" at the
+// start of msg. If it finds one, it returns the marker's level and msg without
+// the marker. Otherwise, it returns level and msg.
+func inferLevel(msg string, level slog.Level) (slog.Level, string) {
+	var word, rest string
+	if strings.HasPrefix(msg, "[") {
+		end := strings.IndexByte(msg, ']')
+		if end < 0 {
+			return level, msg
+		}
+		word, rest = msg[1:end], msg[end+1:]
+	} else {
+		end := strings.IndexByte(msg, ':')
+		if end < 0 {
+			return level, msg
+		}
+		word, rest = msg[:end], msg[end+1:]
+	}
+	if len(word) > maxMarkerLen {
+		return level, msg
+	}
+	l, ok := levelMarkers[strings.ToLower(word)]
+	if !ok {
+		return level, msg
+	}
+	return l, strings.TrimLeft(rest, " ")
+}
+
+// logCaller returns the program counter of the code that called package log.
+func logCaller() uintptr {
+	var pcs [16]uintptr
+	// Skip runtime.Callers, logCaller, and logWriter.Write.
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	inLog := false
+	for {
+		f, more := frames.Next()
+		if strings.HasPrefix(f.Function, "log.") {
+			inLog = true
+		} else if inLog {
+			// f.PC is the call instruction, but slog expects a
+			// return address, as runtime.Callers gives.
+			return f.PC + 1
+		}
+		if !more {
+			return 0
+		}
+	}
+}
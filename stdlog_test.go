@@ -0,0 +1,86 @@
+package humane_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func TestNewLogLogger(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	h := humane.NewHandler(&buf, sourceOpts())
+	logger := humane.NewLogLogger(h, slog.LevelWarn)
+	logger.Printf("disk at %d%%", 91)
+	_, _, line, _ := runtime.Caller(0)
+	logger.SetPrefix("lib: ")
+	logger.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+	logger.Print("[ERROR] not inferred")
+	got := buf.String()
+	want := fmt.Sprintf(
+		" WARN | disk at 91%% | source=stdlog_test.go:%d\n WARN | [ERROR] not inferred | source=stdlog_test.go:%d\n",
+		line-1,
+		line+3,
+	)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestNewLogLoggerMsgPrefix(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	h := humane.NewHandler(&buf, &humane.Options{ReplaceAttr: removeTime})
+	logger := humane.NewLogLogger(h, slog.LevelInfo)
+	logger.SetPrefix("lib: ")
+	logger.SetFlags(log.Ldate | log.Lmsgprefix)
+	logger.Print("foo")
+	got := buf.String()
+	want := " INFO | foo |\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+// RedirectStdLog changes the standard logger, so this test cannot run in
+// parallel with others.
+//
+//nolint:paralleltest
+func TestRedirectStdLog(t *testing.T) {
+	var buf bytes.Buffer
+	h := humane.NewHandler(&buf, &humane.Options{ReplaceAttr: removeTime})
+	var before bytes.Buffer
+	log.SetOutput(&before)
+	log.SetPrefix("pre: ")
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetPrefix("")
+		log.SetFlags(log.LstdFlags)
+	})
+	restore := humane.RedirectStdLog(h, &humane.LogOptions{InferLevel: true})
+	log.Print("[WARN] slow")
+	log.Print("error: failed")
+	log.Print("Debug: hidden")
+	log.Print("[Deprecated] plain")
+	log.Print("plain: text")
+	restore()
+	log.Print("after")
+	got := buf.String()
+	want := " WARN | slow |\n" +
+		"ERROR | failed |\n" +
+		" INFO | [Deprecated] plain |\n" +
+		" INFO | plain: text |\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if got := before.String(); got != "pre: after\n" {
+		t.Errorf("after restore: got %q; want %q", got, "pre: after\n")
+	}
+}
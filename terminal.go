@@ -0,0 +1,65 @@
+package humane
+
+import (
+	"io"
+	"os"
+)
+
+// A When determines whether the handler uses a feature that suits terminals
+// better than files or pipes.
+type When int
+
+const (
+	// Auto uses the feature only if the handler writes to a terminal.
+	Auto When = iota
+
+	// Always uses the feature whatever the handler writes to.
+	Always
+
+	// Never does not use the feature.
+	Never
+)
+
+var whenNames = []string{"auto", "always", "never"}
+
+// String returns the name of w.
+func (w When) String() string {
+	return enumString(whenNames, "When", w)
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (w When) MarshalText() ([]byte, error) {
+	return marshalEnum(whenNames, "When", w)
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (w *When) UnmarshalText(data []byte) error {
+	return unmarshalEnum(whenNames, "When", data, w)
+}
+
+// isTerminal reports whether w is a terminal. It does not look through
+// wrappers such as [bufio.Writer].
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// enabled reports whether the handler should use a feature given whether its
+// writer is a terminal.
+func (w When) enabled(terminal bool) bool {
+	switch w {
+	case Always:
+		return true
+	case Never:
+		return false
+	default:
+		return terminal
+	}
+}
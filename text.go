@@ -0,0 +1,41 @@
+package humane
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// enumString returns the name of e or, if e has no name, the type and number.
+// The option types that are enums use enumString, marshalEnum, and
+// unmarshalEnum to implement [fmt.Stringer], [encoding.TextMarshaler], and
+// [encoding.TextUnmarshaler], so that they can be read from environment
+// variables, flags, or configuration files. Each value has a lower-case name
+// (e.g., "since-start" for TimeSinceStart).
+func enumString[E ~int](names []string, typ string, e E) string {
+	if e < 0 || int(e) >= len(names) {
+		return typ + "(" + strconv.Itoa(int(e)) + ")"
+	}
+	return names[e]
+}
+
+// marshalEnum returns the name of e or an error if e has no name.
+func marshalEnum[E ~int](names []string, typ string, e E) ([]byte, error) {
+	if e < 0 || int(e) >= len(names) {
+		return nil, fmt.Errorf("humane: invalid %s %d", typ, int(e))
+	}
+	return []byte(names[e]), nil
+}
+
+// unmarshalEnum sets *e to the value whose name is data, ignoring case and
+// surrounding spaces.
+func unmarshalEnum[E ~int](names []string, typ string, data []byte, e *E) error {
+	s := strings.TrimSpace(string(data))
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			*e = E(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("humane: unknown %s %q (want %s)", typ, s, strings.Join(names, ", "))
+}
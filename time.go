@@ -0,0 +1,224 @@
+package humane
+
+import (
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/telemachus/humane/internal/buffer"
+	"github.com/telemachus/humane/internal/width"
+)
+
+// A TimeMode determines how the handler displays the time of a record.
+type TimeMode int
+
+const (
+	// TimeAbsolute displays the time of a record using TimeFormat. This
+	// is the default.
+	TimeAbsolute TimeMode = iota
+
+	// TimeSinceStart displays the time elapsed since the program started
+	// (e.g., "+12.345s").
+	TimeSinceStart
+
+	// TimeSinceLast displays the time elapsed since the previous record
+	// that the handler logged (e.g., "Δ3ms"). The first record shows
+	// "Δ0s". When goroutines log at the same time, the handler measures
+	// from the latest record that it has formatted, which may not be the
+	// line just above, and a record older than that one shows "Δ0s"
+	// rather than a negative time.
+	TimeSinceLast
+
+	// TimeClock displays only the time of day with millisecond precision
+	// (e.g., "10:50:09.123").
+	TimeClock
+)
+
+var timeModeNames = []string{"absolute", "since-start", "since-last", "clock"}
+
+// String returns the name of t.
+func (t TimeMode) String() string {
+	return enumString(timeModeNames, "TimeMode", t)
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (t TimeMode) MarshalText() ([]byte, error) {
+	return marshalEnum(timeModeNames, "TimeMode", t)
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (t *TimeMode) UnmarshalText(data []byte) error {
+	return unmarshalEnum(timeModeNames, "TimeMode", data, t)
+}
+
+// A TimePlacement determines where the handler displays the time of a record.
+type TimePlacement int
+
+const (
+	// TimeTrailing displays the time as the last Attr of a record (e.g.,
+	// "INFO | msg | foo=bar time=10:50:09"). This is the default.
+	TimeTrailing TimePlacement = iota
+
+	// TimeLeading displays the time as the first column of a record
+	// (e.g., "10:50:09  INFO | msg | foo=bar"). The time has no key, it
+	// is not quoted, and it is padded to a fixed width for its TimeMode
+	// and TimeFormat.
+	TimeLeading
+)
+
+var timePlacementNames = []string{"trailing", "leading"}
+
+// String returns the name of t.
+func (t TimePlacement) String() string {
+	return enumString(timePlacementNames, "TimePlacement", t)
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (t TimePlacement) MarshalText() ([]byte, error) {
+	return marshalEnum(timePlacementNames, "TimePlacement", t)
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (t *TimePlacement) UnmarshalText(data []byte) error {
+	return unmarshalEnum(timePlacementNames, "TimePlacement", data, t)
+}
+
+// clockFormat is the layout for TimeClock.
+const clockFormat = "15:04:05.000"
+
+// processStart approximates the start of the program. Strictly speaking, it
+// records when this package was initialized, but that happens before main
+// runs.
+var processStart = time.Now()
+
+// lastTime holds the time of the latest record in Unix nanoseconds. A
+// handler and all of its clones share one lastTime.
+type lastTime struct {
+	atomic.Int64
+}
+
+// since returns the time from the latest record to t, and it records t if t
+// is later. It returns zero for the first record and for a record older than
+// the latest one, which concurrent records can produce, so that a delta is
+// never negative. Since it runs outside the lock that orders writes, so that
+// formatting a record never holds that lock, the latest record may not be
+// the one written just before.
+func (l *lastTime) since(t time.Time) time.Duration {
+	n := t.UnixNano()
+	for {
+		prev := l.Load()
+		if n <= prev {
+			return 0
+		}
+		if l.CompareAndSwap(prev, n) {
+			if prev == 0 {
+				return 0
+			}
+			return time.Duration(n - prev)
+		}
+	}
+}
+
+// timeAttr returns the time Attr for a record after applying ReplaceAttr. It
+// reports false if the record has no time or if ReplaceAttr removes the Attr.
+func (h *handler) timeAttr(t time.Time) (slog.Attr, bool) {
+	if t.IsZero() {
+		return slog.Attr{}, false
+	}
+	a := slog.Time(slog.TimeKey, t)
+	if h.replaceAttr != nil {
+		a = h.replaceAttr(nil, a)
+	}
+	return a, !a.Equal(slog.Attr{})
+}
+
+// appendTimeVal writes the value of a trailing time Attr.
+func (h *handler) appendTimeVal(buf *buffer.Buffer, a slog.Attr) {
+	if a.Value.Kind() != slog.KindTime || h.timeMode == TimeAbsolute {
+		h.appendVal(buf, a.Key, a.Value, h.maxValueLen)
+		return
+	}
+	h.appendTime(buf, a.Value.Time())
+}
+
+// appendTimeColumn writes the value of a leading time Attr. Unlike
+// appendTimeVal, it never quotes a time.
+func (h *handler) appendTimeColumn(buf *buffer.Buffer, val slog.Value) {
+	if val.Kind() != slog.KindTime {
+		h.appendVal(buf, slog.TimeKey, val, h.maxValueLen)
+		return
+	}
+	h.appendTime(buf, val.Time())
+}
+
+// appendTimeField writes the {time} field of a layout. format, if not empty,
+// overrides TimeFormat.
+func (h *handler) appendTimeField(buf *buffer.Buffer, val slog.Value, format string) {
+	if format != "" && val.Kind() == slog.KindTime && h.timeMode == TimeAbsolute {
+		*buf = val.Time().AppendFormat(*buf, format)
+		return
+	}
+	h.appendTimeColumn(buf, val)
+}
+
+// The widths of a {time} field in the modes that display elapsed time: a
+// TimeSinceStart time under about three hours (e.g., "+9999.999s") and a
+// TimeSinceLast delta under a minute (e.g., "Δ59.999999s").
+const (
+	sinceStartWidth = 10
+	sinceLastWidth  = 11
+)
+
+// timeWidth returns the width in cells of the {time} field with the given
+// format, so that the fields after it line up from record to record. format,
+// if not empty, overrides TimeFormat.
+func (h *handler) timeWidth(format string) int {
+	switch h.timeMode {
+	case TimeSinceStart:
+		return sinceStartWidth
+	case TimeSinceLast:
+		return sinceLastWidth
+	case TimeClock:
+		return len(clockFormat)
+	}
+	if format == "" {
+		format = h.timeFormat
+	}
+	return formatWidth(format)
+}
+
+// formatWidth returns the widest that a time formatted with format can be. It
+// formats the 28th of each month of 2009, late in the evening and with a full
+// fraction of a second, since those days fall on every day of the week and
+// so cover every name, number, and zone that can vary in width.
+func formatWidth(format string) int {
+	var w int
+	var buf []byte
+	for m := time.January; m <= time.December; m++ {
+		t := time.Date(2009, m, 28, 22, 59, 59, 999999999, time.Local)
+		buf = t.AppendFormat(buf[:0], format)
+		w = max(w, width.Bytes(buf))
+	}
+	return w
+}
+
+// appendTime writes t according to the handler's TimeMode.
+func (h *handler) appendTime(buf *buffer.Buffer, t time.Time) {
+	switch h.timeMode {
+	case TimeSinceStart:
+		d := t.Sub(processStart)
+		if d >= 0 {
+			buf.WriteByte('+')
+		}
+		*buf = strconv.AppendFloat(*buf, d.Seconds(), 'f', 3, 64)
+		buf.WriteByte('s')
+	case TimeSinceLast:
+		buf.WriteString("Δ")
+		buf.WriteString(h.last.since(t).Round(time.Microsecond).String())
+	case TimeClock:
+		*buf = t.AppendFormat(*buf, clockFormat)
+	default:
+		*buf = t.AppendFormat(*buf, h.timeFormat)
+	}
+}
@@ -0,0 +1,250 @@
+package humane_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/telemachus/humane"
+)
+
+func TestTimeModeSinceLast(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{TimeMode: humane.TimeSinceLast}
+	h := humane.NewHandler(&buf, opts)
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	times := []time.Time{
+		start,
+		start.Add(3 * time.Millisecond),
+		start.Add(1503 * time.Millisecond),
+	}
+	for _, tm := range times {
+		r := slog.NewRecord(tm, slog.LevelInfo, "foo", 0)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := buf.String()
+	want := " INFO | foo | time=Δ0s\n" +
+		" INFO | foo | time=Δ3ms\n" +
+		" INFO | foo | time=Δ1.5s\n"
+	if got != want {
+		t.Errorf("TimeSinceLast = %q; want %q", got, want)
+	}
+}
+
+func TestTimeModeSinceLastOutOfOrder(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{TimeMode: humane.TimeSinceLast}
+	h := humane.NewHandler(&buf, opts)
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	// A goroutine can format a record after another goroutine has
+	// formatted a later one.
+	times := []time.Time{
+		start,
+		start.Add(2 * time.Second),
+		start.Add(time.Second),
+		start.Add(3 * time.Second),
+	}
+	for _, tm := range times {
+		r := slog.NewRecord(tm, slog.LevelInfo, "foo", 0)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := buf.String()
+	want := " INFO | foo | time=Δ0s\n" +
+		" INFO | foo | time=Δ2s\n" +
+		" INFO | foo | time=Δ0s\n" +
+		" INFO | foo | time=Δ1s\n"
+	if got != want {
+		t.Errorf("TimeSinceLast = %q; want %q", got, want)
+	}
+}
+
+func TestTimeModeSinceLastSharedByClones(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{TimeMode: humane.TimeSinceLast}
+	h := humane.NewHandler(&buf, opts)
+	h2 := h.WithAttrs([]slog.Attr{slog.Int("a", 1)})
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	r := slog.NewRecord(start, slog.LevelInfo, "foo", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	r = slog.NewRecord(start.Add(time.Second), slog.LevelInfo, "bar", 0)
+	if err := h2.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := " INFO | foo | time=Δ0s\n INFO | bar | a=1 time=Δ1s\n"
+	if got != want {
+		t.Errorf("TimeSinceLast (+WithAttrs) = %q; want %q", got, want)
+	}
+}
+
+func TestTimeModeSinceStart(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{TimeMode: humane.TimeSinceStart}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo")
+	got := buf.String()
+	re := regexp.MustCompile(`^ INFO \| foo \| time=\+\d+\.\d{3}s\n$`)
+	if !re.MatchString(got) {
+		t.Errorf("TimeSinceStart = %q; want match for %s", got, re)
+	}
+}
+
+func TestTimeModeClock(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{TimeMode: humane.TimeClock, TimeFormat: time.RFC3339}
+	h := humane.NewHandler(&buf, opts)
+	tm := time.Date(2009, time.November, 10, 23, 4, 5, 6e6, time.UTC)
+	r := slog.NewRecord(tm, slog.LevelInfo, "foo", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := " INFO | foo | time=23:04:05.006\n"
+	if got != want {
+		t.Errorf("TimeClock = %q; want %q", got, want)
+	}
+}
+
+func TestTimeModeKeepsTimeAttrs(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		TimeMode:    humane.TimeSinceStart,
+		TimeFormat:  "2006-01-02",
+		ReplaceAttr: removeTime,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	logger.Info("foo", slog.Time("when", tm))
+	got := buf.String()
+	if !strings.Contains(got, "when=2009-11-10") {
+		t.Errorf("TimeSinceStart changed a time attr: got %q", got)
+	}
+}
+
+func TestTimePlacementLeading(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		TimeFormat:    time.Kitchen,
+		TimePlacement: humane.TimeLeading,
+		Level:         slog.LevelDebug,
+	}
+	h := humane.NewHandler(&buf, opts)
+	tm := time.Date(2009, time.November, 10, 10, 50, 9, 0, time.UTC)
+	for _, level := range []slog.Level{slog.LevelInfo, slog.LevelDebug} {
+		r := slog.NewRecord(tm, level, "foo", 0)
+		r.AddAttrs(slog.String("a", "b"))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := buf.String()
+	want := "10:50AM  INFO | foo | a=b\n" +
+		"10:50AM DEBUG | foo | a=b\n"
+	if got != want {
+		t.Errorf("TimeLeading = %q; want %q", got, want)
+	}
+}
+
+func TestTimePlacementLeadingWithMode(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		TimeMode:      humane.TimeClock,
+		TimePlacement: humane.TimeLeading,
+	}
+	h := humane.NewHandler(&buf, opts)
+	tm := time.Date(2009, time.November, 10, 10, 50, 9, 0, time.UTC)
+	r := slog.NewRecord(tm, slog.LevelWarn, "foo", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := "10:50:09.000  WARN | foo |\n"
+	if got != want {
+		t.Errorf("TimeLeading (+TimeClock) = %q; want %q", got, want)
+	}
+}
+
+func TestTimePlacementLeadingRemoveTime(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := &humane.Options{
+		ReplaceAttr:   removeTime,
+		TimePlacement: humane.TimeLeading,
+	}
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logger.Info("foo")
+	got := buf.String()
+	want := " INFO | foo |\n"
+	if got != want {
+		t.Errorf("TimeLeading (+removeTime) = %q; want %q", got, want)
+	}
+}
+
+func TestTimePlacementLeadingAligned(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2009, time.November, 10, 9, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		opts  humane.Options
+		times []time.Time
+		want  string
+	}{
+		"kitchen": {
+			opts:  humane.Options{TimeFormat: time.Kitchen},
+			times: []time.Time{start, start.Add(time.Hour)},
+			want: "9:00AM   INFO | foo |\n" +
+				"10:00AM  INFO | foo |\n",
+		},
+		"since last": {
+			opts: humane.Options{TimeMode: humane.TimeSinceLast},
+			times: []time.Time{
+				start,
+				start.Add(3 * time.Millisecond),
+				start.Add(1506 * time.Millisecond),
+			},
+			want: "Δ0s          INFO | foo |\n" +
+				"Δ3ms         INFO | foo |\n" +
+				"Δ1.503s      INFO | foo |\n",
+		},
+		"layout": {
+			opts:  humane.Options{Layout: "{level} {time:Jan _2 3:04PM} | {msg}"},
+			times: []time.Time{start, start.AddDate(0, 1, 0).Add(time.Hour)},
+			want: " INFO Nov 10 9:00AM  | foo\n" +
+				" INFO Dec 10 10:00AM | foo\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			tc.opts.TimePlacement = humane.TimeLeading
+			h := humane.NewHandler(&buf, &tc.opts)
+			for _, tm := range tc.times {
+				r := slog.NewRecord(tm, slog.LevelInfo, "foo", 0)
+				if err := h.Handle(context.Background(), r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("TimeLeading = %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package humane
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// LogSkip emits a log record with the current time and the given level,
+// message, and arguments, as [log/slog.Logger.Log] does. skip is the number
+// of helper functions to skip when finding the source: 0 means the function
+// that calls LogSkip, 1 means the function that calls that function, and so
+// on. If ctx is nil, LogSkip uses [context.Background].
+//
+// If you log from inside a helper function or a wrapper around slog, the
+// source Attr normally points at the helper rather than at the code that
+// called it. LogSkip, [LogAttrsSkip], and [Logger] skip over helpers, so that
+// the source Attr points where it should. They work with any
+// [log/slog.Handler], not only with humane's.
+func LogSkip(ctx context.Context, logger *slog.Logger, skip int, level slog.Level, msg string, args ...any) {
+	logSkip(ctx, logger, skip, level, msg, args, nil)
+}
+
+// LogAttrsSkip is like [LogSkip], but it accepts only Attrs, as
+// [log/slog.Logger.LogAttrs] does.
+func LogAttrsSkip(ctx context.Context, logger *slog.Logger, skip int, level slog.Level, msg string, attrs ...slog.Attr) {
+	logSkip(ctx, logger, skip, level, msg, nil, attrs)
+}
+
+// logSkip must be called directly by an exported function or method, since
+// it skips itself and that caller.
+func logSkip(ctx context.Context, logger *slog.Logger, skip int, level slog.Level, msg string, args []any, attrs []slog.Attr) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	// Skip runtime.Callers, logSkip, and the exported caller of logSkip.
+	runtime.Callers(3+skip, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	r.AddAttrs(attrs...)
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// A Logger wraps a [log/slog.Logger] for use inside a logging package or
+// helper. Its methods work like those of a [log/slog.Logger], but the source
+// Attr of each record points past a fixed number of helper functions.
+//
+// For example, a logging package that wraps slog might do the following.
+//
+//	var logger = humane.NewLogger(slog.Default(), 1)
+//
+//	func Info(msg string, args ...any) {
+//		logger.Info(msg, args...) // The source is the caller of Info.
+//	}
+type Logger struct {
+	logger *slog.Logger
+	skip   int
+}
+
+// NewLogger returns a Logger that logs to logger. skip is the number of
+// helper functions between the code that should appear as the source and the
+// Logger's methods.
+func NewLogger(logger *slog.Logger, skip int) *Logger {
+	return &Logger{logger: logger, skip: skip}
+}
+
+// Slog returns the underlying [log/slog.Logger].
+func (l *Logger) Slog() *slog.Logger {
+	return l.logger
+}
+
+// Enabled reports whether l emits log records at the given level.
+func (l *Logger) Enabled(ctx context.Context, level slog.Level) bool {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return l.logger.Enabled(ctx, level)
+}
+
+// With returns a Logger that includes the given attributes in each record.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{logger: l.logger.With(args...), skip: l.skip}
+}
+
+// WithGroup returns a Logger that starts a group.
+func (l *Logger) WithGroup(name string) *Logger {
+	return &Logger{logger: l.logger.WithGroup(name), skip: l.skip}
+}
+
+// Log emits a log record with the current time and the given level, message,
+// and arguments.
+func (l *Logger) Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	logSkip(ctx, l.logger, l.skip, level, msg, args, nil)
+}
+
+// LogAttrs is a more efficient version of [Logger.Log] that accepts only
+// Attrs.
+func (l *Logger) LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	logSkip(ctx, l.logger, l.skip, level, msg, nil, attrs)
+}
+
+// Debug logs at [log/slog.LevelDebug].
+func (l *Logger) Debug(msg string, args ...any) {
+	logSkip(context.Background(), l.logger, l.skip, slog.LevelDebug, msg, args, nil)
+}
+
+// Info logs at [log/slog.LevelInfo].
+func (l *Logger) Info(msg string, args ...any) {
+	logSkip(context.Background(), l.logger, l.skip, slog.LevelInfo, msg, args, nil)
+}
+
+// Warn logs at [log/slog.LevelWarn].
+func (l *Logger) Warn(msg string, args ...any) {
+	logSkip(context.Background(), l.logger, l.skip, slog.LevelWarn, msg, args, nil)
+}
+
+// Error logs at [log/slog.LevelError].
+func (l *Logger) Error(msg string, args ...any) {
+	logSkip(context.Background(), l.logger, l.skip, slog.LevelError, msg, args, nil)
+}
@@ -0,0 +1,94 @@
+package humane_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func logHelper(logger *slog.Logger, msg string) {
+	humane.LogSkip(context.Background(), logger, 1, slog.LevelInfo, msg, "a", 1)
+}
+
+func logAttrsHelper(logger *slog.Logger, msg string) {
+	humane.LogAttrsSkip(nil, logger, 1, slog.LevelWarn, msg, slog.Int("a", 1)) //nolint:staticcheck // Test a nil context.
+}
+
+type wrapper struct {
+	logger *humane.Logger
+}
+
+func (w wrapper) info(msg string) {
+	w.logger.Info(msg)
+}
+
+func sourceOpts() *humane.Options {
+	return &humane.Options{
+		ReplaceAttr: removeTime,
+		AddSource:   true,
+		SourceMode:  humane.SourceBase,
+	}
+}
+
+func TestLogSkip(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(humane.NewHandler(&buf, sourceOpts()))
+	logHelper(logger, "foo")
+	_, _, line, _ := runtime.Caller(0)
+	logAttrsHelper(logger, "bar")
+	got := buf.String()
+	want := fmt.Sprintf(
+		" INFO | foo | a=1 source=wrap_test.go:%d\n WARN | bar | a=1 source=wrap_test.go:%d\n",
+		line-1,
+		line+1,
+	)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestLogSkipDisabled(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	opts := sourceOpts()
+	opts.Level = slog.LevelError
+	logger := slog.New(humane.NewHandler(&buf, opts))
+	logHelper(logger, "foo")
+	if got := buf.String(); got != "" {
+		t.Errorf("got %q; want nothing", got)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(humane.NewHandler(&buf, sourceOpts()))
+	w := wrapper{humane.NewLogger(logger, 1).With("a", 1).WithGroup("g")}
+	w.info("foo")
+	_, _, line, _ := runtime.Caller(0)
+	got := buf.String()
+	want := fmt.Sprintf(" INFO | foo | a=1 g.source=wrap_test.go:%d\n", line-1)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestLoggerNoSkip(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := humane.NewLogger(slog.New(humane.NewHandler(&buf, sourceOpts())), 0)
+	logger.Error("foo", "b", 2)
+	_, _, line, _ := runtime.Caller(0)
+	logger.LogAttrs(context.Background(), slog.LevelDebug, "not logged")
+	got := buf.String()
+	want := fmt.Sprintf("ERROR | foo | b=2 source=wrap_test.go:%d\n", line-1)
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package humane
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const defaultErrorInterval = time.Minute
+
+// A WriteError reports that the handler could not write a record.
+//
+// The handler reports write errors to Options.OnWriteError at most once per
+// Options.ErrorInterval. Suppressed counts the failures that the handler did
+// not report since the previous report.
+type WriteError struct {
+	Err        error
+	Suppressed int
+}
+
+func (e *WriteError) Error() string {
+	msg := "humane: write failed: " + e.Err.Error()
+	if e.Suppressed > 0 {
+		msg += " (" + strconv.Itoa(e.Suppressed) + " more failures not reported)"
+	}
+	return msg
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// output holds what the handler needs to know about failed writes. A handler
+// and all of its clones share one output, and they guard it with their
+// shared mutex.
+type output struct {
+	lastReport time.Time
+	fallback   io.Writer
+	lastErr    error
+	onError    func(error)
+	now        func() time.Time // tests may replace it
+	interval   time.Duration
+	retries    int
+	suppressed int
+}
+
+func newOutput(opts *Options) *output {
+	out := &output{
+		fallback: opts.Fallback,
+		onError:  opts.OnWriteError,
+		interval: opts.ErrorInterval,
+		retries:  opts.WriteRetries,
+		now:      time.Now,
+	}
+	if out.interval <= 0 {
+		out.interval = defaultErrorInterval
+	}
+	return out
+}
+
+// LastWriteError returns the error from the most recent write by h or by any
+// handler derived from h. It returns nil if that write succeeded, if h has not
+// written anything yet, or if h is not a humane handler. A write counts as
+// failed even if the record went to Options.Fallback instead.
+func LastWriteError(h slog.Handler) error {
+	hh, ok := h.(*handler)
+	if !ok {
+		return nil
+	}
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	return hh.out.lastErr
+}
+
+// write writes a formatted record and deals with any failure. If the primary
+// writer fails but Fallback succeeds, write returns nil.
+func (h *handler) write(p []byte) error {
+	h.mu.Lock()
+	err := h.out.writeAll(h.w, p)
+	h.out.lastErr = err
+	var report error
+	if err != nil {
+		report = h.out.report(err)
+		if h.out.fallback != nil && h.out.writeAll(h.out.fallback, p) == nil {
+			err = nil
+		}
+	}
+	h.mu.Unlock()
+	// Call OnWriteError without the lock, so that it may safely log.
+	if report != nil {
+		h.out.onError(report)
+	}
+	return err
+}
+
+// writeAll writes p to w. After a short write, it retries the rest of p up to
+// the configured number of times.
+func (out *output) writeAll(w io.Writer, p []byte) error {
+	n, err := w.Write(p)
+	for i := 0; i < out.retries && n < len(p) && shortWrite(n, err); i++ {
+		p = p[n:]
+		n, err = w.Write(p)
+	}
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// shortWrite reports whether a write made progress or failed only because it
+// was short, so that it is worth trying again.
+func shortWrite(n int, err error) bool {
+	return n > 0 || err == nil || errors.Is(err, io.ErrShortWrite)
+}
+
+// report returns the error to pass to OnWriteError, or nil if there is no
+// callback or if the handler reported an error too recently.
+func (out *output) report(err error) error {
+	if out.onError == nil {
+		return nil
+	}
+	now := out.now()
+	if !out.lastReport.IsZero() && now.Sub(out.lastReport) < out.interval {
+		out.suppressed++
+		return nil
+	}
+	we := &WriteError{Err: err, Suppressed: out.suppressed}
+	out.lastReport = now
+	out.suppressed = 0
+	return we
+}
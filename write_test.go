@@ -0,0 +1,163 @@
+package humane_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/telemachus/humane"
+)
+
+var errDiskFull = errors.New("disk full")
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errDiskFull
+}
+
+// shortWriter writes at most max bytes per call.
+type shortWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.max {
+		n, _ := w.Buffer.Write(p[:w.max])
+		return n, io.ErrShortWrite
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWriteErrorCallback(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var reports []error
+	opts := &humane.Options{
+		ReplaceAttr: removeTime,
+		OnWriteError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, err)
+		},
+		ErrorInterval: time.Hour,
+	}
+	h := humane.NewHandler(failWriter{}, opts)
+	logger := slog.New(h)
+	for i := 0; i < 3; i++ {
+		logger.Info("foo")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) != 1 {
+		t.Fatalf("OnWriteError called %d times; want 1", len(reports))
+	}
+	var we *humane.WriteError
+	if !errors.As(reports[0], &we) || !errors.Is(we, errDiskFull) {
+		t.Errorf("OnWriteError got %v; want *humane.WriteError wrapping %v", reports[0], errDiskFull)
+	}
+	if err := humane.LastWriteError(h.WithGroup("g")); !errors.Is(err, errDiskFull) {
+		t.Errorf("humane.LastWriteError = %v; want %v", err, errDiskFull)
+	}
+}
+
+func TestWriteErrorSuppressedCount(t *testing.T) {
+	t.Parallel()
+	var reports []*humane.WriteError
+	opts := &humane.Options{
+		OnWriteError: func(err error) {
+			var we *humane.WriteError
+			if errors.As(err, &we) {
+				reports = append(reports, we)
+			}
+		},
+		ErrorInterval: time.Minute,
+	}
+	h := humane.NewHandler(failWriter{}, opts)
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	humane.SetWriteClock(h, func() time.Time { return now })
+	logger := slog.New(h)
+	for i := 0; i < 3; i++ {
+		logger.Info("foo")
+	}
+	now = now.Add(time.Minute)
+	logger.Info("foo")
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports; want 2", len(reports))
+	}
+	if reports[0].Suppressed != 0 || reports[1].Suppressed != 2 {
+		t.Errorf("Suppressed = %d, %d; want 0, 2", reports[0].Suppressed, reports[1].Suppressed)
+	}
+	want := "disk full (2 more failures not reported)"
+	if got := reports[1].Error(); !strings.Contains(got, want) {
+		t.Errorf("Error() = %q; want it to contain %q", got, want)
+	}
+}
+
+func TestWriteFallback(t *testing.T) {
+	t.Parallel()
+	var fallback bytes.Buffer
+	opts := &humane.Options{ReplaceAttr: removeTime, Fallback: &fallback}
+	h := humane.NewHandler(failWriter{}, opts)
+	logger := slog.New(h)
+	logger.Info("foo")
+	got := fallback.String()
+	want := " INFO | foo |\n"
+	if got != want {
+		t.Errorf("Fallback got %q; want %q", got, want)
+	}
+	if err := humane.LastWriteError(h); !errors.Is(err, errDiskFull) {
+		t.Errorf("humane.LastWriteError = %v; want %v", err, errDiskFull)
+	}
+}
+
+func TestWriteRetries(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		want    string
+		name    string
+		retries int
+		wantErr bool
+	}{
+		{name: "no retries", retries: 0, want: " INFO", wantErr: true},
+		{name: "too few retries", retries: 1, want: " INFO | fo", wantErr: true},
+		{name: "enough retries", retries: 5, want: " INFO | foo |\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			w := &shortWriter{max: 5}
+			opts := &humane.Options{ReplaceAttr: removeTime, WriteRetries: tc.retries}
+			h := humane.NewHandler(w, opts)
+			slog.New(h).Info("foo")
+			if got := w.String(); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+			if err := humane.LastWriteError(h); (err != nil) != tc.wantErr {
+				t.Errorf("humane.LastWriteError = %v; want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLastWriteErrorClears(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	h := humane.NewHandler(&buf, nil)
+	if err := humane.LastWriteError(h); err != nil {
+		t.Errorf("humane.LastWriteError before writing = %v; want nil", err)
+	}
+	slog.New(h).Info("foo")
+	if err := humane.LastWriteError(h); err != nil {
+		t.Errorf("humane.LastWriteError after success = %v; want nil", err)
+	}
+	if err := humane.LastWriteError(slog.NewTextHandler(&buf, nil)); err != nil {
+		t.Errorf("humane.LastWriteError(slog.TextHandler) = %v; want nil", err)
+	}
+}
@@ -0,0 +1,102 @@
+package humane
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// maxWriterLine is the longest line that a Writer logs as one record.
+const maxWriterLine = 64 << 10
+
+var errWriterClosed = errors.New("humane: write to closed Writer")
+
+// NewWriter returns an [io.WriteCloser] that logs each line written to it as
+// a record with the given level and attributes. It is meant for output that
+// comes from elsewhere, such as a subprocess.
+//
+//	w := humane.NewWriter(logger, slog.LevelWarn, "cmd", "ffmpeg", "stream", "stderr")
+//	defer w.Close()
+//	cmd.Stderr = w
+//
+// The Writer buffers a partial line until the rest of it arrives, and Close
+// logs any partial line that remains. A line longer than 64 KiB is logged as
+// several records. The Writer drops a trailing "\r" from each line, and it
+// skips empty lines. Records from a Writer have no source. A Writer is safe
+// for concurrent use.
+func NewWriter(logger *slog.Logger, level slog.Level, attrs ...any) io.WriteCloser {
+	return &lineWriter{h: logger.With(attrs...).Handler(), level: level}
+}
+
+type lineWriter struct {
+	h      slog.Handler
+	buf    []byte
+	mu     sync.Mutex
+	level  slog.Level
+	closed bool
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errWriterClosed
+	}
+	n := len(p)
+	var err error
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.buf = append(w.buf, p...)
+			break
+		}
+		w.buf = append(w.buf, p[:i]...)
+		err = errors.Join(err, w.flushLong(), w.log(w.buf))
+		w.buf = w.buf[:0]
+		p = p[i+1:]
+	}
+	return n, errors.Join(err, w.flushLong())
+}
+
+// Close logs any partial line. Writes after Close fail.
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.log(w.buf)
+	w.buf = nil
+	return err
+}
+
+// flushLong logs the start of the buffered line while it is too long.
+func (w *lineWriter) flushLong() error {
+	var err error
+	for len(w.buf) > maxWriterLine {
+		cut := runeStart(w.buf, maxWriterLine)
+		if cut == 0 {
+			cut = maxWriterLine
+		}
+		err = errors.Join(err, w.log(w.buf[:cut]))
+		w.buf = w.buf[:copy(w.buf, w.buf[cut:])]
+	}
+	return err
+}
+
+func (w *lineWriter) log(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if len(line) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	if !w.h.Enabled(ctx, w.level) {
+		return nil
+	}
+	return w.h.Handle(ctx, slog.NewRecord(time.Now(), w.level, string(line), 0))
+}
@@ -0,0 +1,78 @@
+package humane_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/telemachus/humane"
+)
+
+func TestWriterLines(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(humane.NewHandler(&buf, sourceOpts()))
+	w := humane.NewWriter(logger, slog.LevelWarn, "cmd", "ffmpeg", "stream", "stderr")
+	for _, s := range []string{"first\r\nsec", "ond\n\n", "third"} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatalf("write %q: %v", s, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	got := buf.String()
+	want := " WARN | first | cmd=ffmpeg stream=stderr\n" +
+		" WARN | second | cmd=ffmpeg stream=stderr\n" +
+		" WARN | third | cmd=ffmpeg stream=stderr\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if _, err := io.WriteString(w, "late\n"); err == nil {
+		t.Error("write after close: got nil error")
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+}
+
+func TestWriterLongLine(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(humane.NewHandler(&buf, &humane.Options{ReplaceAttr: removeTime}))
+	w := humane.NewWriter(logger, slog.LevelInfo)
+	line := strings.Repeat("é", 40<<10)
+	if _, err := io.WriteString(w, line+"\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	records := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(records) != 2 {
+		t.Fatalf("got %d records; want 2", len(records))
+	}
+	var msgs strings.Builder
+	for _, r := range records {
+		msg := strings.TrimSuffix(strings.TrimPrefix(r, " INFO | "), " |")
+		if len(msg) > 64<<10 {
+			t.Errorf("record is %d bytes; want at most %d", len(msg), 64<<10)
+		}
+		msgs.WriteString(msg)
+	}
+	if msgs.String() != line {
+		t.Error("records do not add up to the line that was written")
+	}
+}
+
+func TestWriterLevel(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(humane.NewHandler(&buf, &humane.Options{ReplaceAttr: removeTime}))
+	w := humane.NewWriter(logger, slog.LevelDebug)
+	if _, err := io.WriteString(w, "hidden\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := buf.String(); got != "" {
+		t.Errorf("got %q; want nothing", got)
+	}
+}